@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"math/bits"
 )
 
 const (
@@ -81,3 +82,24 @@ func encodeBCH15_5(data uint, poly uint) uint {
 	// The remainder is the lower 10 bits of d.
 	return d & 0x3FF // Mask to 10 bits
 }
+
+// DecodeFormatInformation returns the error correction level and mask
+// pattern whose format information sequence is closest to the given one.
+// Up to 3 bit errors are corrected.
+func DecodeFormatInformation(formatInfo uint16) (errcorr, int, error) {
+	bestDistance := 16
+	var bestLevel errcorr
+	var bestMask int
+	for _, level := range []errcorr{ERR_CORR_L, ERR_CORR_M, ERR_CORR_Q, ERR_CORR_H} {
+		for mask := range 8 {
+			candidate, _ := GenerateFormatInformation(level, mask)
+			if d := bits.OnesCount16(candidate ^ formatInfo); d < bestDistance {
+				bestDistance, bestLevel, bestMask = d, level, mask
+			}
+		}
+	}
+	if bestDistance > 3 {
+		return "", 0, errors.New("unrecoverable format information")
+	}
+	return bestLevel, bestMask, nil
+}
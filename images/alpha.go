@@ -0,0 +1,35 @@
+package images
+
+import (
+	"image"
+	"image/color"
+)
+
+// Coverage resamples the alpha channel of img to a w by h grid. Each cell
+// holds the mean opacity of the pixels it covers, or of the nearest pixel
+// when the grid is finer than the image.
+func Coverage(img image.Image, w, h int) *image.Alpha {
+	bounds := img.Bounds()
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	if bounds.Empty() {
+		return mask
+	}
+	for cy := range h {
+		y0 := bounds.Min.Y + cy*bounds.Dy()/h
+		y1 := max(bounds.Min.Y+(cy+1)*bounds.Dy()/h, y0+1)
+		for cx := range w {
+			x0 := bounds.Min.X + cx*bounds.Dx()/w
+			x1 := max(bounds.Min.X+(cx+1)*bounds.Dx()/w, x0+1)
+			var sum, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					_, _, _, a := img.At(x, y).RGBA()
+					sum += uint64(a)
+					n++
+				}
+			}
+			mask.SetAlpha(cx, cy, color.Alpha{A: uint8(sum / n >> 8)})
+		}
+	}
+	return mask
+}
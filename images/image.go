@@ -3,91 +3,273 @@ package images
 import (
 	"image"
 	"image/color"
+	"image/draw"
 	_ "image/gif"  // Register GIF decoder
 	_ "image/jpeg" // Register JPEG decoder
-	"image/png"    // Register PNG encoder
+	_ "image/png"  // Register PNG decoder
 	"math"
 	"os"
 )
 
-func MakeLogo(path string) string {
-	// 1. Open the original image
+// subpixel samples per side used to antialias mask edges
+const maskSamples = 4
+
+// Mask is the outline a logo is cut to. The curved masks match the module
+// shapes of the writer package.
+type Mask string
+
+const (
+	MaskNone     Mask = "none"
+	MaskCircle   Mask = "circle"
+	MaskRounded  Mask = "rounded"
+	MaskSquircle Mask = "squircle"
+)
+
+// Recolor selects how the logo colors are replaced.
+type Recolor string
+
+const (
+	RecolorNone Recolor = "none"
+	// RecolorMono paints every pixel with the dark color, keeping its
+	// alpha, which turns the logo into a silhouette.
+	RecolorMono Recolor = "mono"
+	// RecolorDuotone maps the luminance of each pixel from the dark color
+	// (black) to the light one (white).
+	RecolorDuotone Recolor = "duotone"
+)
+
+// Options describes the steps of MakeLogo. Zero values skip a step.
+type Options struct {
+	Mask Mask
+	// Key is the background color made transparent, see RemoveBackground.
+	Key color.Color
+	// KeyTolerance is the color distance, from 0 to 1, under which pixels
+	// match Key. Zero selects 0.1.
+	KeyTolerance float64
+	// Padding is the transparent margin added on each side, as a fraction
+	// of the cropped side.
+	Padding float64
+	// Size is the side of the result in pixels.
+	Size    int
+	Recolor Recolor
+	Dark    color.Color
+	Light   color.Color
+}
+
+const defaultKeyTolerance = 0.1
+
+// MakeLogo loads the image at path and prepares it for the center of a
+// symbol: center-crop to a square, background removal, recoloring,
+// masking, padding and resizing, in that order.
+func MakeLogo(path string, opts Options) (*image.NRGBA, error) {
+	img, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return Process(img, opts), nil
+}
+
+// Load decodes the PNG, JPEG or GIF file at path.
+func Load(path string) (image.Image, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer file.Close()
-
-	// 2. Decode the image (handles jpg, png, gif if imported)
 	img, _, err := image.Decode(file)
-	if err != nil {
-		panic(err)
+	return img, err
+}
+
+// Process runs the steps of MakeLogo on img.
+func Process(img image.Image, opts Options) *image.NRGBA {
+	logo := CenterCrop(img)
+	if opts.Key != nil {
+		tolerance := opts.KeyTolerance
+		if tolerance <= 0 {
+			tolerance = defaultKeyTolerance
+		}
+		RemoveBackground(logo, opts.Key, tolerance)
+	}
+	switch opts.Recolor {
+	case RecolorMono:
+		Monochrome(logo, opts.Dark)
+	case RecolorDuotone:
+		Duotone(logo, opts.Dark, opts.Light)
+	}
+	if opts.Mask != "" && opts.Mask != MaskNone {
+		ApplyMask(logo, opts.Mask)
+	}
+	if opts.Padding > 0 {
+		logo = Pad(logo, int(math.Round(opts.Padding*float64(logo.Rect.Dx()))))
+	}
+	if opts.Size > 0 {
+		logo = Resize(logo, opts.Size)
 	}
+	return logo
+}
 
-	// 3. Create a new image with Alpha channel (RGBA)
-	// We need a new canvas because JPEGs generally don't have transparency
+// CenterCrop copies the largest centered square of img, with its origin at
+// (0, 0).
+func CenterCrop(img image.Image) *image.NRGBA {
 	bounds := img.Bounds()
-	rgba := image.NewRGBA(bounds)
-
-	// 4. Trim image to centered square
 	size := min(bounds.Dx(), bounds.Dy())
-	offsetX := (bounds.Dx() - size) / 2
-	offsetY := (bounds.Dy() - size) / 2
-	for y := range size {
-		for x := range size {
-			originalColor := img.At(x+offsetX, y+offsetY)
-			r, g, b, _ := originalColor.RGBA()
-			// 5. Set alpha to 0
-			rgba.Set(x, y, color.RGBA{
-				R: uint8(r >> 8),
-				G: uint8(g >> 8),
-				B: uint8(b >> 8),
-				A: 0,
-			})
+	offset := image.Pt((bounds.Dx()-size)/2, (bounds.Dy()-size)/2).Add(bounds.Min)
+	square := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(square, square.Rect, img, offset, draw.Src)
+	return square
+}
+
+// CornerColor returns the color of the top left pixel of img, a common
+// guess for its background.
+func CornerColor(img image.Image) color.Color {
+	return img.At(img.Bounds().Min.X, img.Bounds().Min.Y)
+}
+
+// RemoveBackground makes the pixels of img close to key transparent. The
+// distance between colors goes from 0 to 1, pixels within tolerance of key
+// disappear and the next tolerance fades in to keep edges smooth.
+func RemoveBackground(img *image.NRGBA, key color.Color, tolerance float64) {
+	k := color.NRGBAModel.Convert(key).(color.NRGBA)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			dr := float64(c.R) - float64(k.R)
+			dg := float64(c.G) - float64(k.G)
+			db := float64(c.B) - float64(k.B)
+			d := math.Sqrt(dr*dr+dg*dg+db*db) / (255 * math.Sqrt(3))
+			opacity := math.Min(math.Max((d-tolerance)/tolerance, 0), 1)
+			c.A = uint8(math.Round(float64(c.A) * opacity))
+			img.SetNRGBA(x, y, c)
 		}
 	}
+}
 
-	// 5. Create circular mask
-	center := size / 2
-	radius := size / 2
-	for y := range size {
-		for x := range size {
-			dx := x - center
-			dy := y - center
-			distance := math.Sqrt(float64(dx*dx + dy*dy))
-			if distance > float64(radius) {
-				// Outside the circle, set alpha to 0
-				r, g, b, _ := rgba.At(x, y).RGBA()
-				rgba.Set(x, y, color.RGBA{
-					R: uint8(r >> 8),
-					G: uint8(g >> 8),
-					B: uint8(b >> 8),
-					A: 0,
-				})
-			} else {
-				// Inside the circle, set alpha to 255
-				r, g, b, _ := rgba.At(x, y).RGBA()
-				rgba.Set(x, y, color.RGBA{
-					R: uint8(r >> 8),
-					G: uint8(g >> 8),
-					B: uint8(b >> 8),
-					A: 255,
-				})
+// Monochrome paints every pixel of img with c, keeping its alpha.
+func Monochrome(img *image.NRGBA, c color.Color) {
+	if c == nil {
+		c = color.Black
+	}
+	ink := color.NRGBAModel.Convert(c).(color.NRGBA)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			a := img.NRGBAAt(x, y).A
+			img.SetNRGBA(x, y, color.NRGBA{R: ink.R, G: ink.G, B: ink.B, A: uint8(uint16(a) * uint16(ink.A) / 0xff)})
+		}
+	}
+}
+
+// Duotone maps the luminance of every pixel of img from dark to light,
+// keeping its alpha.
+func Duotone(img *image.NRGBA, dark, light color.Color) {
+	if dark == nil {
+		dark = color.Black
+	}
+	if light == nil {
+		light = color.White
+	}
+	d := color.NRGBAModel.Convert(dark).(color.NRGBA)
+	l := color.NRGBAModel.Convert(light).(color.NRGBA)
+	mix := func(a, b uint8, t float64) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			t := float64(color.GrayModel.Convert(color.NRGBA{c.R, c.G, c.B, 0xff}).(color.Gray).Y) / 0xff
+			img.SetNRGBA(x, y, color.NRGBA{R: mix(d.R, l.R, t), G: mix(d.G, l.G, t), B: mix(d.B, l.B, t), A: c.A})
+		}
+	}
+}
+
+// ApplyMask makes the pixels of img outside mask transparent, with
+// antialiased edges.
+func ApplyMask(img *image.NRGBA, mask Mask) {
+	w, h := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			inside := 0
+			for sy := range maskSamples {
+				for sx := range maskSamples {
+					// Sample position from -1 to 1 across the image
+					u := 2*(float64(x-img.Rect.Min.X)+(float64(sx)+.5)/maskSamples)/w - 1
+					v := 2*(float64(y-img.Rect.Min.Y)+(float64(sy)+.5)/maskSamples)/h - 1
+					if insideMask(mask, u, v) {
+						inside++
+					}
+				}
 			}
+			c := img.NRGBAAt(x, y)
+			c.A = uint8(int(c.A) * inside / (maskSamples * maskSamples))
+			img.SetNRGBA(x, y, c)
 		}
 	}
+}
 
-	// 6. Save as PNG
-	outFile, err := os.Create("logo.png")
-	if err != nil {
-		panic(err)
+// insideMask reports whether (u, v), from -1 to 1 on both axes, lies within
+// mask
+func insideMask(mask Mask, u, v float64) bool {
+	switch mask {
+	case MaskCircle:
+		return u*u+v*v <= 1
+	case MaskRounded:
+		// same corner radius as the rounded module, 0.35 of the side
+		const r = 0.7
+		qx := max(math.Abs(u)-(1-r), 0)
+		qy := max(math.Abs(v)-(1-r), 0)
+		return qx*qx+qy*qy <= r*r
+	case MaskSquircle:
+		// superellipse |x|^4 + |y|^4 = 1, as the squircle module
+		return u*u*u*u+v*v*v*v <= 1
 	}
-	defer outFile.Close()
+	return true
+}
 
-	// Encode takes the writer and the image
-	err = png.Encode(outFile, rgba)
-	if err != nil {
-		panic(err)
+// Pad returns img with a transparent margin of padding pixels on each side.
+func Pad(img *image.NRGBA, padding int) *image.NRGBA {
+	padded := image.NewNRGBA(image.Rect(0, 0, img.Rect.Dx()+2*padding, img.Rect.Dy()+2*padding))
+	draw.Draw(padded, img.Rect.Sub(img.Rect.Min).Add(image.Pt(padding, padding)), img, img.Rect.Min, draw.Src)
+	return padded
+}
+
+// Resize scales img to a square of size pixels. Every output pixel averages
+// the source pixels it covers, weighted by the overlap and their alpha.
+func Resize(img *image.NRGBA, size int) *image.NRGBA {
+	resized := image.NewNRGBA(image.Rect(0, 0, size, size))
+	src := img.Rect
+	if src.Empty() {
+		return resized
+	}
+	sx := float64(src.Dx()) / float64(size)
+	sy := float64(src.Dy()) / float64(size)
+	for y := range size {
+		y0, y1 := float64(y)*sy, float64(y+1)*sy
+		for x := range size {
+			x0, x1 := float64(x)*sx, float64(x+1)*sx
+			// premultiplied sums
+			var r, g, b, a, weight float64
+			for py := int(y0); float64(py) < y1 && py < src.Dy(); py++ {
+				wy := math.Min(y1, float64(py+1)) - math.Max(y0, float64(py))
+				for px := int(x0); float64(px) < x1 && px < src.Dx(); px++ {
+					w := wy * (math.Min(x1, float64(px+1)) - math.Max(x0, float64(px)))
+					c := img.NRGBAAt(src.Min.X+px, src.Min.Y+py)
+					alpha := float64(c.A) * w
+					r += float64(c.R) * alpha
+					g += float64(c.G) * alpha
+					b += float64(c.B) * alpha
+					a += alpha
+					weight += w
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			resized.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(math.Round(r / a)),
+				G: uint8(math.Round(g / a)),
+				B: uint8(math.Round(b / a)),
+				A: uint8(math.Round(a / weight)),
+			})
+		}
 	}
-	return "logo.png"
+	return resized
 }
@@ -0,0 +1,93 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// solid returns a w by h image filled with c
+func solid(w, h int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Rect, image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestProcessMask(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	logo := Process(solid(60, 40, red), Options{Mask: MaskCircle})
+	if logo.Rect != image.Rect(0, 0, 40, 40) {
+		t.Fatalf("cropped to %v", logo.Rect)
+	}
+	// Inside the circle the logo stays opaque, the corners disappear
+	if c := logo.NRGBAAt(20, 20); c != red {
+		t.Errorf("center is %v", c)
+	}
+	if c := logo.NRGBAAt(0, 0); c.A != 0 {
+		t.Errorf("corner is %v", c)
+	}
+	if c := logo.NRGBAAt(5, 5); c.A == 0 || c.A == 0xff {
+		t.Errorf("edge is %v, want antialiased", c)
+	}
+}
+
+func TestRemoveBackground(t *testing.T) {
+	img := solid(4, 4, color.White)
+	img.Set(1, 1, color.NRGBA{R: 0xf8, G: 0xf8, B: 0xf8, A: 0xff})
+	img.Set(2, 2, color.Black)
+	RemoveBackground(img, CornerColor(img), 0.1)
+	for _, p := range []image.Point{{0, 0}, {1, 1}} {
+		if a := img.NRGBAAt(p.X, p.Y).A; a != 0 {
+			t.Errorf("%v kept alpha %d", p, a)
+		}
+	}
+	if a := img.NRGBAAt(2, 2).A; a != 0xff {
+		t.Errorf("foreground alpha %d", a)
+	}
+}
+
+func TestRecolor(t *testing.T) {
+	green := color.NRGBA{G: 0x80, A: 0xff}
+	img := solid(2, 1, color.White)
+	img.Set(1, 0, color.NRGBA{A: 0x80})
+	Monochrome(img, green)
+	if c := img.NRGBAAt(0, 0); c != green {
+		t.Errorf("mono white is %v", c)
+	}
+	if c := img.NRGBAAt(1, 0); c != (color.NRGBA{G: 0x80, A: 0x80}) {
+		t.Errorf("mono translucent is %v", c)
+	}
+
+	img = solid(2, 1, color.White)
+	img.Set(1, 0, color.Black)
+	Duotone(img, green, color.White)
+	if c := img.NRGBAAt(0, 0); c != (color.NRGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("duotone white is %v", c)
+	}
+	if c := img.NRGBAAt(1, 0); c != green {
+		t.Errorf("duotone black is %v", c)
+	}
+}
+
+func TestPadResize(t *testing.T) {
+	logo := Process(solid(10, 10, color.Black), Options{Padding: 0.5, Size: 4})
+	if logo.Rect != image.Rect(0, 0, 4, 4) {
+		t.Fatalf("resized to %v", logo.Rect)
+	}
+	// 10 pixels padded by 5 on each side, the outer pixels are transparent
+	// and the inner ones cover the logo
+	if a := logo.NRGBAAt(0, 0).A; a != 0 {
+		t.Errorf("padding alpha %d", a)
+	}
+	if c := logo.NRGBAAt(1, 1); c != (color.NRGBA{A: 0xff}) {
+		t.Errorf("logo pixel is %v", c)
+	}
+
+	// Transparent pixels do not darken the average
+	img := solid(2, 1, color.White)
+	img.Set(1, 0, color.Transparent)
+	if c := Resize(img, 1).NRGBAAt(0, 0); c != (color.NRGBA{0xff, 0xff, 0xff, 0x80}) {
+		t.Errorf("averaged to %v", c)
+	}
+}
@@ -0,0 +1,242 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+)
+
+const (
+	// pixels sampled at most by Palette
+	paletteSamples = 1 << 16
+	// k-means passes refining the median cut
+	paletteIterations = 8
+	// pixels below this opacity are not part of the logo
+	paletteAlpha = 0x80
+	// swatches smaller than this share of the logo are ignored by
+	// ThemeColor
+	themeMinWeight = 0.05
+	// chroma, from 0 to 1, under which a swatch counts as gray
+	themeMinChroma = 0.1
+)
+
+// Swatch is a dominant color of an image with its share of the opaque
+// pixels.
+type Swatch struct {
+	Color  color.NRGBA
+	Weight float64
+}
+
+// Palette returns up to n dominant colors of the opaque pixels of img, most
+// common first. Colors are grouped by median cut, the box of colors with
+// the widest channel range being split at its median until there are n
+// boxes, then refined with k-means.
+func Palette(img image.Image, n int) []Swatch {
+	bounds := img.Bounds()
+	step := max(1, int(math.Sqrt(float64(bounds.Dx()*bounds.Dy())/paletteSamples)))
+	var pixels [][3]uint8
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A >= paletteAlpha {
+				pixels = append(pixels, [3]uint8{c.R, c.G, c.B})
+			}
+		}
+	}
+	if len(pixels) == 0 || n < 1 {
+		return nil
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < n {
+		// Split the box with the widest channel range
+		widest, channel, width := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for ch := range 3 {
+				lo, hi := uint8(0xff), uint8(0)
+				for _, p := range box {
+					lo, hi = min(lo, p[ch]), max(hi, p[ch])
+				}
+				if int(hi)-int(lo) > width {
+					widest, channel, width = i, ch, int(hi)-int(lo)
+				}
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		box := boxes[widest]
+		slices.SortFunc(box, func(a, b [3]uint8) int { return int(a[channel]) - int(b[channel]) })
+		boxes[widest] = box[:len(box)/2]
+		boxes = append(boxes, box[len(box)/2:])
+	}
+
+	// The box means seed a few k-means iterations, which moves colors that
+	// the median split put in the same box to their nearest cluster
+	centers := make([][3]float64, len(boxes))
+	for i, box := range boxes {
+		for _, p := range box {
+			for ch := range 3 {
+				centers[i][ch] += float64(p[ch]) / float64(len(box))
+			}
+		}
+	}
+	counts := make([]int, len(centers))
+	for range paletteIterations {
+		sums := make([][3]float64, len(centers))
+		clear(counts)
+		for _, p := range pixels {
+			nearest, best := 0, math.Inf(1)
+			for i, c := range centers {
+				d := 0.
+				for ch := range 3 {
+					d += (float64(p[ch]) - c[ch]) * (float64(p[ch]) - c[ch])
+				}
+				if d < best {
+					nearest, best = i, d
+				}
+			}
+			for ch := range 3 {
+				sums[nearest][ch] += float64(p[ch])
+			}
+			counts[nearest]++
+		}
+		for i := range centers {
+			if counts[i] > 0 {
+				for ch := range 3 {
+					centers[i][ch] = sums[i][ch] / float64(counts[i])
+				}
+			}
+		}
+	}
+
+	var swatches []Swatch
+	for i, c := range centers {
+		if counts[i] == 0 {
+			continue
+		}
+		swatches = append(swatches, Swatch{
+			Color: color.NRGBA{
+				R: uint8(math.Round(c[0])),
+				G: uint8(math.Round(c[1])),
+				B: uint8(math.Round(c[2])),
+				A: 0xff,
+			},
+			Weight: float64(counts[i]) / float64(len(pixels)),
+		})
+	}
+	slices.SortStableFunc(swatches, func(a, b Swatch) int {
+		switch {
+		case a.Weight > b.Weight:
+			return -1
+		case a.Weight < b.Weight:
+			return 1
+		}
+		return 0
+	})
+	return swatches
+}
+
+// Luminance returns the WCAG relative luminance of c, from 0 for black to 1
+// for white.
+func Luminance(c color.Color) float64 {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return 1
+	}
+	linear := func(v uint32) float64 {
+		// Undo the alpha premultiplication, then the sRGB transfer curve
+		s := float64(v) / float64(a)
+		if s <= 0.04045 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b)
+}
+
+// ContrastRatio returns the WCAG contrast ratio between a and b, from 1 for
+// the same luminance to 21 for black on white.
+func ContrastRatio(a, b color.Color) float64 {
+	la, lb := Luminance(a), Luminance(b)
+	return (max(la, lb) + 0.05) / (min(la, lb) + 0.05)
+}
+
+// ThemeColor picks the color of a code drawn with the logo img over
+// background: the dominant color of the logo furthest in luminance from
+// the background, the darkest on light backgrounds and the lightest on
+// dark ones, with at least minContrast against it. Swatches under 5% of
+// the logo are ignored, and gray ones only count when no colored swatch
+// has enough contrast. When no swatch does, the best colored one is
+// darkened, or lightened on dark backgrounds, until it has. ok is false
+// when img has no opaque pixels.
+func ThemeColor(img image.Image, background color.Color, minContrast float64) (c color.NRGBA, ok bool) {
+	var brand, gray []color.NRGBA
+	for _, s := range Palette(img, 8) {
+		switch {
+		case s.Weight < themeMinWeight:
+		case chroma(s.Color) < themeMinChroma:
+			gray = append(gray, s.Color)
+		default:
+			brand = append(brand, s.Color)
+		}
+	}
+	// Codes over dark backgrounds are drawn in light colors
+	lighten := ContrastRatio(color.White, background) > ContrastRatio(color.Black, background)
+	// better sorts the swatches furthest from the background first
+	better := func(a, b color.NRGBA) int {
+		la, lb := Luminance(a), Luminance(b)
+		if lighten {
+			la, lb = lb, la
+		}
+		switch {
+		case la < lb:
+			return -1
+		case la > lb:
+			return 1
+		}
+		return 0
+	}
+	slices.SortFunc(brand, better)
+	slices.SortFunc(gray, better)
+	for _, candidates := range [][]color.NRGBA{brand, gray} {
+		if len(candidates) > 0 && ContrastRatio(candidates[0], background) >= minContrast {
+			return candidates[0], true
+		}
+	}
+
+	if len(brand) == 0 {
+		brand = gray
+	}
+	if len(brand) == 0 {
+		return color.NRGBA{}, false
+	}
+	// Scale the channels toward black, or toward white, in small steps,
+	// which keeps the hue
+	best := brand[0]
+	channel := func(v uint8, f float64) uint8 {
+		if lighten {
+			return 0xff - uint8(float64(0xff-v)*f)
+		}
+		return uint8(float64(v) * f)
+	}
+	for f := 1.0; f > 0; f -= 0.02 {
+		shade := color.NRGBA{R: channel(best.R, f), G: channel(best.G, f), B: channel(best.B, f), A: 0xff}
+		if ContrastRatio(shade, background) >= minContrast {
+			return shade, true
+		}
+	}
+	if lighten {
+		return color.NRGBA{0xff, 0xff, 0xff, 0xff}, true
+	}
+	return color.NRGBA{A: 0xff}, true
+}
+
+// chroma returns the spread of the channels of c, from 0 for grays to 1
+func chroma(c color.NRGBA) float64 {
+	return float64(max(c.R, c.G, c.B)-min(c.R, c.G, c.B)) / 0xff
+}
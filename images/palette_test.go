@@ -0,0 +1,98 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+// stripes returns an image made of vertical bands of the given colors and
+// widths
+func stripes(colors []color.Color, widths []int) *image.NRGBA {
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, total, 10))
+	x := 0
+	for i, c := range colors {
+		draw.Draw(img, image.Rect(x, 0, x+widths[i], 10), image.NewUniform(c), image.Point{}, draw.Src)
+		x += widths[i]
+	}
+	return img
+}
+
+func TestPalette(t *testing.T) {
+	blue := color.NRGBA{0, 0x67, 0xa6, 0xff}
+	orange := color.NRGBA{0xf2, 0x65, 0x23, 0xff}
+	img := stripes([]color.Color{blue, orange, color.Transparent}, []int{30, 10, 60})
+	palette := Palette(img, 4)
+	if len(palette) != 2 {
+		t.Fatalf("palette %v", palette)
+	}
+	if palette[0].Color != blue || palette[0].Weight != 0.75 || palette[1].Color != orange {
+		t.Errorf("palette %v", palette)
+	}
+}
+
+func TestContrastRatio(t *testing.T) {
+	if r := ContrastRatio(color.Black, color.White); math.Abs(r-21) > 1e-9 {
+		t.Errorf("black on white %g", r)
+	}
+	if r := ContrastRatio(color.White, color.White); r != 1 {
+		t.Errorf("white on white %g", r)
+	}
+}
+
+func TestThemeColor(t *testing.T) {
+	blue := color.NRGBA{0, 0x67, 0xa6, 0xff}
+	yellow := color.NRGBA{0xfe, 0xcd, 0, 0xff}
+	dark := color.NRGBA{0x19, 0x19, 0x19, 0xff}
+	tests := []struct {
+		name string
+		img  image.Image
+		want color.NRGBA
+	}{
+		{"darkest brand color", stripes([]color.Color{yellow, blue, color.White}, []int{10, 10, 10}), blue},
+		{"gray when the brand color is too light", stripes([]color.Color{yellow, dark}, []int{10, 10}), dark},
+		// yellow scaled by 0.56 is the first shade with 4.5:1 on white
+		{"darkened brand color", stripes([]color.Color{yellow, color.White}, []int{10, 10}), color.NRGBA{0x8e, 0x72, 0, 0xff}},
+	}
+	for _, tc := range tests {
+		c, ok := ThemeColor(tc.img, color.White, 4.5)
+		if !ok || c != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, c, tc.want)
+		}
+		if ContrastRatio(c, color.White) < 4.5 {
+			t.Errorf("%s: contrast %g", tc.name, ContrastRatio(c, color.White))
+		}
+	}
+}
+
+func TestThemeColorDarkBackground(t *testing.T) {
+	background := color.NRGBA{0x10, 0x10, 0x20, 0xff}
+	blue := color.NRGBA{0, 0x67, 0xa6, 0xff}
+	yellow := color.NRGBA{0xfe, 0xcd, 0, 0xff}
+	light := color.NRGBA{0xe0, 0xe0, 0xe0, 0xff}
+	tests := []struct {
+		name string
+		img  image.Image
+		want color.NRGBA
+	}{
+		{"lightest brand color", stripes([]color.Color{blue, yellow, color.Black}, []int{10, 10, 10}), yellow},
+		{"gray when the brand color is too dark", stripes([]color.Color{blue, light}, []int{10, 10}), light},
+		// blue moved 18% of the way to white is the first tint with 4.5:1
+		{"lightened brand color", stripes([]color.Color{blue, color.Black}, []int{10, 10}), color.NRGBA{0x2e, 0x83, 0xb7, 0xff}},
+	}
+	for _, tc := range tests {
+		c, ok := ThemeColor(tc.img, background, 4.5)
+		if !ok || c != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, c, tc.want)
+		}
+		if ContrastRatio(c, background) < 4.5 {
+			t.Errorf("%s: contrast %g", tc.name, ContrastRatio(c, background))
+		}
+	}
+}
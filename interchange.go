@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/harogaston/go-mosaic/version"
+	"github.com/harogaston/go-mosaic/writer"
+)
+
+// MatrixFormat is a file format used to exchange module matrices with
+// other tools.
+type MatrixFormat string
+
+const (
+	MatrixJSON MatrixFormat = "json" // matrix, role map and symbol metadata
+	MatrixText MatrixFormat = "txt"  // one line per row, '#' dark and '.' light
+	MatrixCSV  MatrixFormat = "csv"  // one record per row, 1 dark and 0 light
+)
+
+const (
+	textDark  = '#'
+	textLight = '.'
+)
+
+// matrixJSON is the JSON representation of a symbol. Modules and roles are
+// stored as one string per row to keep files small and diffable.
+type matrixJSON struct {
+	Version    int               `json:"version"`
+	Level      string            `json:"level"`
+	Mask       int               `json:"mask"`
+	Size       int               `json:"size"`
+	Modules    []string          `json:"modules"`
+	Roles      []string          `json:"roles,omitempty"`
+	RoleLegend map[string]string `json:"role_legend,omitempty"`
+	Codewords  []int             `json:"codewords,omitempty"`
+}
+
+// MatrixFormatFromPath picks the interchange format from a file extension.
+func MatrixFormatFromPath(path string) (MatrixFormat, error) {
+	switch f := MatrixFormat(strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")); f {
+	case MatrixJSON, MatrixText, MatrixCSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown matrix format for %q", path)
+}
+
+// Export writes the module matrix in the given format.
+func (qr *qr) Export(w io.Writer, format MatrixFormat) error {
+	switch format {
+	case MatrixJSON:
+		return qr.exportJSON(w)
+	case MatrixText:
+		bw := bufio.NewWriter(w)
+		for _, row := range qr.matrix {
+			for _, m := range row {
+				if m.bit == One {
+					bw.WriteByte(textDark)
+				} else {
+					bw.WriteByte(textLight)
+				}
+			}
+			bw.WriteByte('\n')
+		}
+		return bw.Flush()
+	case MatrixCSV:
+		cw := csv.NewWriter(w)
+		record := make([]string, qr.size)
+		for _, row := range qr.matrix {
+			for x, m := range row {
+				record[x] = "0"
+				if m.bit == One {
+					record[x] = "1"
+				}
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown matrix format %q", format)
+	}
+}
+
+func (qr *qr) exportJSON(w io.Writer) error {
+	out := matrixJSON{
+		Version:    qr.version.Number,
+		Level:      string(qr.error_corr_level),
+		Mask:       qr.mask,
+		Size:       qr.size,
+		RoleLegend: map[string]string{},
+	}
+	for _, row := range qr.matrix {
+		var b strings.Builder
+		for _, m := range row {
+			if m.bit == One {
+				b.WriteByte(textDark)
+			} else {
+				b.WriteByte(textLight)
+			}
+		}
+		out.Modules = append(out.Modules, b.String())
+	}
+	for _, row := range qr.Roles() {
+		b := make([]byte, len(row))
+		for x, r := range row {
+			b[x] = roleLetter(r)
+			out.RoleLegend[string(roleLetter(r))] = string(r)
+		}
+		out.Roles = append(out.Roles, string(b))
+	}
+	for _, c := range qr.codewords {
+		out.Codewords = append(out.Codewords, int(c))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// ImportQRCode reads a module matrix in the given format. Version, error
+// correction level, mask and codewords are recovered from the matrix, so
+// the result can be drawn like a symbol created with NewQRCode.
+func ImportQRCode(r io.Reader, format MatrixFormat) (*qr, error) {
+	switch format {
+	case MatrixJSON:
+		return importJSON(r)
+	case MatrixText:
+		var rows []string
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				rows = append(rows, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		dark, err := parseTextRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		return qrFromMatrix(dark)
+	case MatrixCSV:
+		records, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		dark := make([][]bool, len(records))
+		for y, record := range records {
+			dark[y] = make([]bool, len(record))
+			for x, field := range record {
+				switch strings.TrimSpace(field) {
+				case "1":
+					dark[y][x] = true
+				case "0":
+				default:
+					return nil, fmt.Errorf("row %d, column %d: invalid module value %q", y+1, x+1, field)
+				}
+			}
+		}
+		return qrFromMatrix(dark)
+	default:
+		return nil, fmt.Errorf("unknown matrix format %q", format)
+	}
+}
+
+func importJSON(r io.Reader) (*qr, error) {
+	var in matrixJSON
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, err
+	}
+	dark, err := parseTextRows(in.Modules)
+	if err != nil {
+		return nil, err
+	}
+	qr, err := qrFromMatrix(dark)
+	if err != nil {
+		return nil, err
+	}
+
+	// metadata is optional, but must agree with the matrix when present
+	if in.Size != 0 && in.Size != qr.size {
+		return nil, fmt.Errorf("size %d does not match the %d module matrix", in.Size, qr.size)
+	}
+	if in.Version != 0 && in.Version != qr.version.Number {
+		return nil, fmt.Errorf("version %d does not match the matrix (version %d)", in.Version, qr.version.Number)
+	}
+	if in.Level != "" && errcorr(in.Level) != qr.error_corr_level {
+		return nil, fmt.Errorf("level %s does not match the format information (level %s)", in.Level, qr.error_corr_level)
+	}
+	if in.Level != "" && in.Mask != qr.mask {
+		return nil, fmt.Errorf("mask %d does not match the format information (mask %d)", in.Mask, qr.mask)
+	}
+	if len(in.Roles) > 0 {
+		roles := qr.Roles()
+		if len(in.Roles) != len(roles) {
+			return nil, errors.New("role map size does not match the matrix")
+		}
+		for y, row := range in.Roles {
+			if len(row) != len(roles[y]) {
+				return nil, fmt.Errorf("role map row %d has %d modules, expected %d", y+1, len(row), len(roles[y]))
+			}
+			for x := range len(row) {
+				role, err := RoleFromLetter(row[x])
+				if err != nil {
+					return nil, fmt.Errorf("role map row %d: %w", y+1, err)
+				}
+				if role != roles[y][x] {
+					return nil, fmt.Errorf("role map does not match version %d at row %d, column %d", qr.version.Number, y+1, x+1)
+				}
+			}
+		}
+	}
+	if len(in.Codewords) > 0 {
+		codewords := make([]byte, len(in.Codewords))
+		for i, c := range in.Codewords {
+			codewords[i] = byte(c)
+		}
+		if !slices.Equal(codewords, qr.codewords) {
+			return nil, errors.New("codewords do not match the module matrix")
+		}
+	}
+	return qr, nil
+}
+
+func parseTextRows(rows []string) ([][]bool, error) {
+	dark := make([][]bool, len(rows))
+	for y, row := range rows {
+		dark[y] = make([]bool, len(row))
+		for x := range len(row) {
+			switch row[x] {
+			case textDark:
+				dark[y][x] = true
+			case textLight:
+			default:
+				return nil, fmt.Errorf("row %d, column %d: invalid module %q", y+1, x+1, row[x])
+			}
+		}
+	}
+	return dark, nil
+}
+
+// builds a symbol from a matrix of dark modules, reading the format
+// information and codewords back from it
+func qrFromMatrix(dark [][]bool) (*qr, error) {
+	size := len(dark)
+	if size < 21 || size > 177 || (size-17)%4 != 0 {
+		return nil, fmt.Errorf("%d rows is not a valid QR Code size", size)
+	}
+	for y, row := range dark {
+		if len(row) != size {
+			return nil, fmt.Errorf("row %d has %d modules, expected %d", y+1, len(row), size)
+		}
+	}
+
+	v := version.QRVersion{Format: version.FORMAT_QR_MODEL_2, Number: (size - 17) / 4}
+	matrix := make([][]module, size)
+	isFunctionPattern := make([][]bool, size)
+	roles := module_roles(v.Number, size)
+	for y := range size {
+		matrix[y] = make([]module, size)
+		isFunctionPattern[y] = make([]bool, size)
+		for x := range size {
+			matrix[y][x] = module{bit: Zero}
+			if dark[y][x] {
+				matrix[y][x] = module{bit: One}
+			}
+			isFunctionPattern[y][x] = roles[y][x] != writer.RoleData
+		}
+	}
+
+	qr := &qr{
+		matrix:                 matrix,
+		is_function_pattern:    isFunctionPattern,
+		version:                v,
+		size:                   size,
+		alignment_patterns_pos: alignment_patterns_positions(v.Number, size),
+	}
+
+	level, mask, err := DecodeFormatInformation(qr.read_format_information())
+	if err != nil {
+		return nil, err
+	}
+	qr.error_corr_level = level
+	qr.mask = mask
+	qr.codewords = qr.read_codewords()
+	return qr, nil
+}
+
+// reads the top left copy of the format information, the inverse of
+// place_format_information
+func (qr *qr) read_format_information() uint16 {
+	positions := make([][2]int, 0, 15)
+	for i := range 6 {
+		positions = append(positions, [2]int{8, i})
+	}
+	positions = append(positions, [2]int{8, 7}, [2]int{8, 8}, [2]int{7, 8})
+	for i := range 6 {
+		positions = append(positions, [2]int{5 - i, 8})
+	}
+
+	var formatInfo uint16
+	for _, pos := range positions {
+		formatInfo <<= 1
+		if qr.matrix[pos[0]][pos[1]].bit == One {
+			formatInfo |= 1
+		}
+	}
+	return formatInfo
+}
+
+// unmasks the data region and reads the interleaved codewords
+func (qr *qr) read_codewords() []byte {
+	unmasked := qr.apply_mask(qr.mask, qr.matrix)
+	total := capacityData[qr.version.Number].totalCodewords
+	codewords := make([]byte, total)
+	for i, pos := range qr.codewordPositions() {
+		if i/8 >= total {
+			break
+		}
+		if unmasked[pos[0]][pos[1]].bit == One {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return codewords
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/harogaston/go-mosaic/writer"
+)
+
+func TestMatrixRoundTrip(t *testing.T) {
+	for _, level := range []string{"L", "M", "Q", "H"} {
+		// Version 7+ also exercises the version information role
+		original := NewQRCode(QRRequest{
+			input_data:     strings.Repeat("Round trip through the interchange formats. ", 4),
+			err_corr_level: level,
+		})
+
+		for _, format := range []MatrixFormat{MatrixJSON, MatrixText, MatrixCSV} {
+			var buf bytes.Buffer
+			if err := original.Export(&buf, format); err != nil {
+				t.Fatalf("%s/%s: export failed: %v", level, format, err)
+			}
+			imported, err := ImportQRCode(&buf, format)
+			if err != nil {
+				t.Fatalf("%s/%s: import failed: %v", level, format, err)
+			}
+
+			if imported.version != original.version {
+				t.Errorf("%s/%s: version %v, expected %v", level, format, imported.version, original.version)
+			}
+			if imported.error_corr_level != original.error_corr_level || imported.mask != original.mask {
+				t.Errorf("%s/%s: got level %s mask %d, expected level %s mask %d", level, format,
+					imported.error_corr_level, imported.mask, original.error_corr_level, original.mask)
+			}
+			for y := range original.matrix {
+				if !slices.Equal(imported.matrix[y], original.matrix[y]) {
+					t.Fatalf("%s/%s: row %d differs", level, format, y)
+				}
+			}
+			if !slices.Equal(imported.codewords, original.codewords) {
+				t.Errorf("%s/%s: codewords differ", level, format)
+			}
+		}
+	}
+}
+
+func TestRolesMatchFunctionPatterns(t *testing.T) {
+	qr := NewQRCode(QRRequest{
+		input_data:     strings.Repeat("0123456789", 30),
+		err_corr_level: "M",
+	})
+	for y, row := range qr.Roles() {
+		for x, role := range row {
+			if (role != writer.RoleData) != qr.is_function_pattern[y][x] {
+				t.Fatalf("module (%d, %d): role %s but function pattern is %t", y, x, role, qr.is_function_pattern[y][x])
+			}
+		}
+	}
+}
+
+func TestImportRejectsInvalidMatrix(t *testing.T) {
+	if _, err := ImportQRCode(strings.NewReader("#.#\n.#.\n#.#\n"), MatrixText); err == nil {
+		t.Error("expected an error for a 3x3 matrix")
+	}
+	if _, err := ImportQRCode(strings.NewReader("1,0,2\n"), MatrixCSV); err == nil {
+		t.Error("expected an error for an invalid module value")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/harogaston/go-mosaic/modes"
+	"github.com/harogaston/go-mosaic/version"
+	"github.com/harogaston/go-mosaic/writer"
+)
+
+// share of the correction capacity of each block a logo may use by default,
+// the rest is left for print defects, glare and dirt
+const defaultLogoSafety = 0.75
+
+// largest logo side tried when sizing it automatically, as a fraction of
+// the symbol side
+const maxLogoRelativeSize = 0.45
+
+// misdecode protection codewords, which reduce the error correction
+// capacity of small symbols (ISO/IEC 18004 table 9)
+var misdecodeProtection = map[int]map[errcorr]int{
+	1: {ERR_CORR_L: 3, ERR_CORR_M: 2, ERR_CORR_Q: 1, ERR_CORR_H: 1},
+	2: {ERR_CORR_L: 2},
+	3: {ERR_CORR_L: 1},
+}
+
+// blockDamage counts the codewords of a Reed-Solomon block hidden by a
+// logo, against the number of codeword errors the block corrects
+type blockDamage struct {
+	damaged  int
+	capacity int
+}
+
+// codewordBlocks returns the Reed-Solomon block of every codeword in
+// placement order, undoing the interleaving of data_and_error_correction
+func (qr *qr) codewordBlocks() []int {
+	ecInfo := capacityData[qr.version.Number].ecInfo[qr.error_corr_level]
+	var dataLengths []int
+	ecLength := 0
+	for _, group := range ecInfo.BlockGroups {
+		for range group.NumBlocks {
+			dataLengths = append(dataLengths, group.DataCodewords)
+			ecLength = group.TotalCodewords - group.DataCodewords
+		}
+	}
+
+	var blocks []int
+	for i := 0; i < slices.Max(dataLengths); i++ {
+		for block, length := range dataLengths {
+			if i < length {
+				blocks = append(blocks, block)
+			}
+		}
+	}
+	for range ecLength {
+		for block := range dataLengths {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// logoDamage returns, per Reed-Solomon block, how many codewords have at
+// least one module for which hidden is true
+func (qr *qr) logoDamage(hidden func(x, y int) bool) []blockDamage {
+	ecInfo := capacityData[qr.version.Number].ecInfo[qr.error_corr_level]
+	var damage []blockDamage
+	for _, group := range ecInfo.BlockGroups {
+		ec := group.TotalCodewords - group.DataCodewords
+		ec -= misdecodeProtection[qr.version.Number][qr.error_corr_level]
+		for range group.NumBlocks {
+			damage = append(damage, blockDamage{capacity: ec / 2})
+		}
+	}
+
+	blocks := qr.codewordBlocks()
+	hit := make([]bool, len(blocks))
+	for i, pos := range qr.codewordPositions() {
+		// Remainder bits past the last codeword carry no data
+		if i/8 >= len(blocks) {
+			break
+		}
+		if !hit[i/8] && hidden(pos[1], pos[0]) {
+			hit[i/8] = true
+			damage[blocks[i/8]].damaged++
+		}
+	}
+	return damage
+}
+
+// checkLogo returns an error naming the worst block when the logo of scene
+// hides more codewords of some block than safety times its capacity. Every
+// module under the logo counts as hidden, around it only the dark modules
+// the clearance turns light do.
+func (qr *qr) checkLogo(scene *writer.Scene, safety float64) error {
+	logo := scene.Logo
+	if logo == nil {
+		return nil
+	}
+	hidden := func(x, y int) bool {
+		return logo.Covered[y][x] || (scene.Cleared(x, y) && scene.Dark(x, y))
+	}
+	allowed := func(d blockDamage) int { return int(math.Floor(safety * float64(d.capacity))) }
+	worst := -1
+	damage := qr.logoDamage(hidden)
+	for i, d := range damage {
+		if d.damaged > allowed(d) && (worst < 0 || d.damaged-allowed(d) > damage[worst].damaged-allowed(damage[worst])) {
+			worst = i
+		}
+	}
+	if worst < 0 {
+		return nil
+	}
+	d := damage[worst]
+	return fmt.Errorf("logo hides %d codewords of block %d of %d, level %s corrects %d and safety %g allows %d",
+		d.damaged, worst+1, len(damage), qr.error_corr_level, d.capacity, safety, allowed(d))
+}
+
+// versionFor returns the smallest version holding data at level, or 0
+// when it does not fit in version 40
+func versionFor(data string, level errcorr) int {
+	mode := modes.GetMode(data)
+	return GetVersionNumber(mode, version.FORMAT_QR_MODEL_2, encode(mode, data), level)
+}
+
+// placeLogo places the logo on the scene of qr, picking the largest logo
+// that fits when opts.RelativeSize is zero
+func (qr *qr) placeLogo(scene *writer.Scene, opts writer.LogoOptions, safety float64) error {
+	if opts.RelativeSize != 0 {
+		if err := scene.PlaceLogo(opts); err != nil {
+			return err
+		}
+		return qr.checkLogo(scene, safety)
+	}
+	dim := scene.Size()
+	var err error
+	for n := int(float64(dim) * maxLogoRelativeSize); n >= 1; n-- {
+		if n%2 == 0 {
+			continue
+		}
+		// Half a module above n keeps the floor in layoutLogo at n
+		opts.RelativeSize = (float64(n) + .5) / float64(dim)
+		// Smaller logos may find a position the larger ones did not
+		if err = scene.PlaceLogo(opts); err != nil {
+			continue
+		}
+		if scene.Logo == nil {
+			break
+		}
+		if err = qr.checkLogo(scene, safety); err == nil {
+			return nil
+		}
+	}
+	scene.Logo = nil
+	if err == nil {
+		err = fmt.Errorf("symbol too small for a logo")
+	}
+	return fmt.Errorf("no logo size fits: %w", err)
+}
+
+// encodeWithLogo encodes req at the first of levels that keeps every block
+// within safety of its correction capacity under the logo, and returns the
+// code with its scene
+func encodeWithLogo(req QRRequest, shape writer.Shape, opts writer.LogoOptions, safety float64, levels []errcorr) (*qr, *writer.Scene, error) {
+	var err error
+	for _, level := range levels {
+		if versionFor(req.input_data, level) == 0 {
+			if err == nil {
+				err = fmt.Errorf("data does not fit at level %s", level)
+			}
+			break
+		}
+		req.err_corr_level = string(level)
+		qr := NewQRCode(req)
+		scene := qr.Scene(shape)
+		if err = qr.placeLogo(scene, opts, safety); err == nil {
+			return qr, scene, nil
+		}
+	}
+	return nil, nil, err
+}
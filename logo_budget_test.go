@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/harogaston/go-mosaic/writer"
+)
+
+func TestCodewordBlocks(t *testing.T) {
+	for _, tc := range []struct {
+		data  string
+		level errcorr
+	}{
+		{"01234567", ERR_CORR_L},
+		{"This is a long string to trigger multiple blocks for Reed-Solomon interleaving verification.", ERR_CORR_Q},
+		{"This is a long string to trigger multiple blocks for Reed-Solomon interleaving verification.", ERR_CORR_H},
+	} {
+		qr := NewQRCode(QRRequest{input_data: tc.data, err_corr_level: string(tc.level)})
+		ecInfo := capacityData[qr.version.Number].ecInfo[tc.level]
+		blocks := qr.codewordBlocks()
+		if len(blocks) != len(qr.codewords) {
+			t.Fatalf("%s: %d blocks for %d codewords", qr.FullVersion(), len(blocks), len(qr.codewords))
+		}
+		counts := map[int]int{}
+		for _, b := range blocks {
+			counts[b]++
+		}
+		block := 0
+		for _, group := range ecInfo.BlockGroups {
+			for range group.NumBlocks {
+				if counts[block] != group.TotalCodewords {
+					t.Errorf("%s: block %d has %d codewords, want %d", qr.FullVersion(), block, counts[block], group.TotalCodewords)
+				}
+				block++
+			}
+		}
+	}
+}
+
+func TestLogoDamage(t *testing.T) {
+	qr := NewQRCode(QRRequest{input_data: "https://example.com/some/longer/path?q=1", err_corr_level: "H"})
+	for _, d := range qr.logoDamage(func(x, y int) bool { return false }) {
+		if d.damaged != 0 {
+			t.Errorf("damage without a logo: %+v", d)
+		}
+	}
+	total := 0
+	for _, d := range qr.logoDamage(func(x, y int) bool { return true }) {
+		total += d.damaged
+	}
+	if total != len(qr.codewords) {
+		t.Errorf("hiding every module damages %d codewords, want %d", total, len(qr.codewords))
+	}
+}
+
+func TestEncodeWithLogo(t *testing.T) {
+	req := QRRequest{input_data: "https://example.com/some/longer/path?q=1"}
+	levels := []errcorr{ERR_CORR_L, ERR_CORR_M, ERR_CORR_Q, ERR_CORR_H}
+	opts := writer.LogoOptions{Href: "logo.png", RelativeSize: 2. / 7.}
+
+	if _, _, err := encodeWithLogo(req, writer.ShapeSquare, opts, 0.75, levels[:1]); err == nil {
+		t.Error("level L accepted a 2/7 logo")
+	}
+	qr, scene, err := encodeWithLogo(req, writer.ShapeSquare, opts, 0.75, levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qr.error_corr_level == ERR_CORR_L || scene.Logo == nil {
+		t.Errorf("level %s, logo %v", qr.error_corr_level, scene.Logo)
+	}
+
+	// The largest logo at a level fits and the next size up does not
+	opts.RelativeSize = 0
+	qr, scene, err = encodeWithLogo(req, writer.ShapeSquare, opts, 0.75, levels[3:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.RelativeSize = (float64(scene.Logo.Size+2) + .5) / float64(scene.Size())
+	if err := scene.PlaceLogo(opts); err != nil {
+		t.Fatal(err)
+	}
+	if qr.checkLogo(scene, 0.75) == nil {
+		t.Errorf("logo of %d modules fits, but %d was picked", scene.Logo.Size, scene.Logo.Size-2)
+	}
+}
@@ -3,13 +3,18 @@ package main
 import (
 	"flag"
 	"fmt"
+	"image"
 	"image/color"
+	"io"
+	"maps"
 	"math"
 	"math/bits"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/harogaston/go-mosaic/bitseq"
+	"github.com/harogaston/go-mosaic/images"
 	"github.com/harogaston/go-mosaic/modes"
 	"github.com/harogaston/go-mosaic/version"
 	"github.com/harogaston/go-mosaic/writer"
@@ -25,17 +30,17 @@ type qr struct {
 	encoded_data           bitseq.BitSeq
 	mode                   modes.QRMode
 	mask                   int
-	logo                   string
 	is_function_pattern    [][]bool
 	alignment_patterns_pos [][]int
+	codewords              []byte
 	debug                  bool
 }
 
 func (qr *qr) DebugPrint() {
 	black := "\u25A0"
 	white := "\u25A1"
-	fmt.Println(qr.String())
-	fmt.Printf("Mode: %s\n", qr.mode)
+	fmt.Fprintln(os.Stderr, qr.String())
+	fmt.Fprintf(os.Stderr, "Mode: %s\n", qr.mode)
 	formatInfo, _ := GenerateFormatInformation(qr.error_corr_level, qr.mask)
 	bs := bitseq.FromInt(uint64(formatInfo), 15)
 	var formatColors []string
@@ -46,7 +51,7 @@ func (qr *qr) DebugPrint() {
 			formatColors = append(formatColors, white)
 		}
 	}
-	fmt.Printf("Mask Pattern: %d %s\n", qr.mask, formatColors)
+	fmt.Fprintf(os.Stderr, "Mask Pattern: %d %s\n", qr.mask, formatColors)
 }
 
 func (qr *qr) generate() {
@@ -90,7 +95,7 @@ func (qr *qr) generate() {
 	}
 
 	if qr.debug {
-		fmt.Println("DEBUG: Masking disabled")
+		fmt.Fprintln(os.Stderr, "DEBUG: Masking disabled")
 		// Use original matrix, but we still need to place format info.
 		// We'll use mask 0 for format info generation, but won't apply the mask XOR to data.
 		qr.matrix = originalMatrix
@@ -319,7 +324,16 @@ func (qr *qr) timing_patterns() {
 
 // places alignment patter modules
 func (qr *qr) alignment_patterns() {
-	coords := get_alignment_patterns_for_version(qr.version.Number)
+	qr.alignment_patterns_pos = alignment_patterns_positions(qr.version.Number, qr.size)
+	for _, alignment_pos := range qr.alignment_patterns_pos {
+		qr.add_alignment_pattern_module(alignment_pos[0], alignment_pos[1])
+	}
+}
+
+// returns the centers of the alignment patterns that do not collide with
+// the finder patterns
+func alignment_patterns_positions(versionNumber int, size int) [][]int {
+	coords := get_alignment_patterns_for_version(versionNumber)
 	alignment_patterns_pos := make([][]int, 0)
 	for _, alignment_pos := range coords {
 		alignment_pattern_upper_left := []int{alignment_pos[0] - 2, alignment_pos[1] - 2}
@@ -334,24 +348,23 @@ func (qr *qr) alignment_patterns() {
 		}
 
 		// check lower left colission
-		finder_upper_right := []int{qr.size - 7, 6}
+		finder_upper_right := []int{size - 7, 6}
 		if finder_upper_right[0] <= alignment_pattern_lower_left[0] &&
 			finder_upper_right[1] >= alignment_pattern_lower_left[1] {
 			continue
 		}
 
 		// check upper right colission
-		finder_lower_left := []int{6, qr.size - 7}
+		finder_lower_left := []int{6, size - 7}
 		if finder_lower_left[0] >= alignment_pattern_upper_right[0] &&
 			finder_lower_left[1] <= alignment_pattern_upper_right[1] {
 			continue
 		}
 
 		// no colissions good to go
-		qr.add_alignment_pattern_module(alignment_pos[0], alignment_pos[1])
 		alignment_patterns_pos = append(alignment_patterns_pos, alignment_pos)
 	}
-	qr.alignment_patterns_pos = alignment_patterns_pos
+	return alignment_patterns_pos
 }
 
 // places an alignment pattern module (5x5) in the given position
@@ -523,8 +536,8 @@ func (qr *qr) data_and_error_correction() {
 			ecBlock := reedSolomonEncode(block, group.TotalCodewords-group.DataCodewords)
 
 			if qr.debug {
-				fmt.Printf("Data Codewords (%d): %v\n", len(block), block)
-				fmt.Printf("EC Codewords (%d): %v\n", len(ecBlock), ecBlock)
+				fmt.Fprintf(os.Stderr, "Data Codewords (%d): %v\n", len(block), block)
+				fmt.Fprintf(os.Stderr, "EC Codewords (%d): %v\n", len(ecBlock), ecBlock)
 			}
 
 			ecBlocks = append(ecBlocks, ecBlock)
@@ -567,19 +580,35 @@ func (qr *qr) data_and_error_correction() {
 	}
 
 	// 5. Place modules
+	qr.codewords = finalMessage
 	qr.placeCodewords(finalMessage)
 }
 
 func (qr *qr) placeCodewords(data []byte) {
+	for i, pos := range qr.codewordPositions() {
+		y, x := pos[0], pos[1]
+
+		// Place bit. Positions past the end of data are remainder bits (always 0)
+		byteIndex, bitIndex := i/8, i%8
+		if byteIndex < len(data) && (data[byteIndex]>>(7-bitIndex))&1 == 1 {
+			qr.matrix[y][x] = module{bit: One}
+		} else {
+			qr.matrix[y][x] = module{bit: Zero}
+		}
+	}
+}
+
+// returns the [row, col] positions available for codeword bits, in
+// placement order
+func (qr *qr) codewordPositions() [][2]int {
+	var positions [][2]int
+
 	// Zig-zag scan
 	// Start at bottom right
 	row := qr.size - 1
 	col := qr.size - 1
 	direction := -1 // -1 for up, 1 for down
 
-	bitIndex := 0
-	byteIndex := 0
-
 	for col > 0 {
 		if col == 6 { // Skip timing pattern column
 			col--
@@ -592,29 +621,7 @@ func (qr *qr) placeCodewords(data []byte) {
 
 				// Skip function patterns
 				if !qr.isFunctionPattern(y, x) {
-					// Place bit
-					var bit int
-					if byteIndex < len(data) {
-						if (data[byteIndex]>>(7-bitIndex))&1 == 1 {
-							bit = 1
-						} else {
-							bit = 0
-						}
-						bitIndex++
-						if bitIndex == 8 {
-							bitIndex = 0
-							byteIndex++
-						}
-					} else {
-						// Remainder bits (should be 0)
-						bit = 0
-					}
-
-					if bit == 1 {
-						qr.matrix[y][x] = module{bit: One}
-					} else {
-						qr.matrix[y][x] = module{bit: Zero}
-					}
+					positions = append(positions, [2]int{y, x})
 				}
 			}
 			row += direction
@@ -623,6 +630,7 @@ func (qr *qr) placeCodewords(data []byte) {
 		direction = -direction // Change direction
 		col -= 2
 	}
+	return positions
 }
 
 // Calculates character count of given input data in the
@@ -731,7 +739,6 @@ func NewQRCode(r QRRequest) *qr {
 		data:                []byte(r.input_data),
 		encoded_data:        output,
 		mode:                mode,
-		logo:                r.logo,
 		debug:               r.debug,
 	}
 	qr.generate()
@@ -780,44 +787,100 @@ func (qr *qr) String() string {
 	return b.String()
 }
 
-func (qr *qr) Draw(shape writer.Shape) {
-	pixs := make([][]color.Color, len(qr.matrix[0]))
+// foreground color of every output
+var defaultColor = color.RGBA{10, 100, 0, 255}
+
+// WCAG contrast the color picked by -color auto keeps against the
+// background, the level of normal text in WCAG AA
+const autoColorContrast = 4.5
+
+// Scene lays out the symbol for the renderers of the writer package
+func (qr *qr) Scene(shape writer.Shape) *writer.Scene {
+	roles := qr.Roles()
+	modules := make([][]writer.Module, len(qr.matrix))
 	for y, row := range qr.matrix {
-		imgRow := make([]color.Color, len(row))
-		for x, c := range row {
-			imgRow[x] = c.Color()
+		modules[y] = make([]writer.Module, len(row))
+		for x, m := range row {
+			modules[y][x] = writer.Module{Dark: m.bit == One, Role: roles[y][x]}
 		}
-		pixs[y] = imgRow
-	}
-
-	req := writer.SVGRequest{
-		Scale:             16,
-		Cells:             pixs,
-		AlignmentPatterns: qr.alignment_patterns_pos,
-		Shape:             shape,
-		Logo:              qr.logo,
-		Color:             color.RGBA{10, 100, 0, 255},
-		Debug:             qr.debug,
 	}
-	writer.WriteSVG(req)
+	scene := writer.NewScene(modules, qr.alignment_patterns_pos, shape)
+	scene.Color = defaultColor
+	scene.Data = string(qr.data)
+	scene.Level = string(qr.error_corr_level)
+	scene.Debug = qr.debug
+	return scene
 }
 
 type QRRequest struct {
 	input_data     string
 	is_micro       bool
 	err_corr_level string
-	logo           string
 	debug          bool
 }
 
 func main() {
 	// Define flags
 	dataStr := flag.String("data", "01234567", "Data to encode in the QR code")
-	shapeStr := flag.String("shape", "square", "Shape: square, circle, rounded, slanted, squircle")
-	levelStr := flag.String("level", "L", "ErrorCorrectionLevel: L, M, Q, H")
-	logoPath := flag.Bool("logo", false, "Include logo (default: resources/logo_circle_mask.png)")
+	colorStr := flag.String("color", "#0a6400", "Color of the dark modules as #rgb, #rrggbb, #rrggbbaa, rgb(), rgba() or a CSS name, cmyk(c, m, y, k) or spot(name, c, m, y, k) for print, or auto to pick it from the logo")
+	backgroundStr := flag.String("background", "white", "Color of the light modules, in the same forms as -color, transparent for none")
+	quietZoneColor := flag.String("quiet-zone-color", "", "Color of the quiet zone, the -background color when empty")
+	finderColor := flag.String("finder-color", "", "Color of the finder patterns, -color when empty")
+	finderCenterColor := flag.String("finder-center-color", "", "Color of the 3x3 center of the finder patterns, -finder-color when empty")
+	alignmentColor := flag.String("alignment-color", "", "Color of the alignment patterns, -color when empty")
+	timingColor := flag.String("timing-color", "", "Color of the timing patterns, -color when empty")
+	dataColor := flag.String("data-color", "", "Color of the data and error correction modules, -color when empty")
+	gradientStr := flag.String("gradient", "none", "Gradient across the dark modules: none, linear, radial")
+	gradientColors := flag.String("gradient-colors", "", "Comma separated gradient colors, in the same forms as -color, spread evenly from start to end")
+	gradientAngle := flag.Float64("gradient-angle", 0, "Direction of a linear gradient in degrees, 0 from left to right, 90 from top to bottom")
+	gradientCenter := flag.String("gradient-center", "0,0", "Center of a radial gradient as x,y fractions of the symbol side from its middle")
+	gradientRadius := flag.Float64("gradient-radius", 0, "Radius of a radial gradient as a fraction of the symbol side, 0 to reach the farthest corner")
+	gradientFinders := flag.Bool("gradient-finders", false, "Paint the finder and alignment patterns with the gradient too, instead of -color")
+	minContrast := flag.Float64("min-contrast", writer.DefaultMinContrast, "Lowest WCAG contrast ratio between the dark and light modules")
+	allowLowContrast := flag.Bool("allow-low-contrast", false, "Write the code with a warning when its contrast is below -min-contrast")
+	backdropPath := flag.String("backdrop", "", "PNG, JPEG or GIF image the code is printed over, checked for contrast behind a translucent -background")
+	shapeStr := flag.String("shape", "square", "Shape: square, circle, rounded, slanted, squircle, or connected, liquid, horizontal, vertical to merge neighboring modules")
+	eyeFrame := flag.String("eye-frame", "", "Shape of the outer ring of the finder and alignment patterns: square, rounded, circle, leaf, teardrop, dotted. Empty follows -shape")
+	eyeBall := flag.String("eye-ball", "", "Shape of the center of the finder and alignment patterns: square, rounded, circle, leaf, teardrop, dotted. Empty follows -shape")
+	levelStr := flag.String("level", "auto", "ErrorCorrectionLevel: L, M, Q, H, or auto for the lowest level the logo allows (L without a logo)")
+	logoPath := flag.String("logo", "", "Logo file drawn over the center: PNG, JPEG, GIF or SVG")
+	logoSize := flag.Float64("logo-size", 2./7., "Side of the logo as a fraction of the symbol side, 0 for the largest that fits")
+	logoSafety := flag.Float64("logo-safety", defaultLogoSafety, "Share of the error correction capacity of each block the logo may use")
+	logoPlate := flag.String("logo-plate", "auto", "Shape of the area cleared behind the logo: auto, circle, square, rounded, none, or shape to follow the logo's opaque pixels")
+	logoMask := flag.String("logo-mask", "none", "Outline the logo image is cut to: none, auto (follows -shape), circle, rounded, squircle")
+	logoKey := flag.String("logo-key", "", "Background color removed from the logo image, in the same forms as -color, or auto for its top left pixel")
+	logoRecolor := flag.String("logo-recolor", "none", "Recolor the logo image: none, mono (silhouette in the code color), duotone (code color to white)")
+	logoPadding := flag.Float64("logo-padding", 0, "Transparent margin added around the logo image, as a fraction of its side")
+	logoPx := flag.Int("logo-px", 0, "Resize the logo image to this side in pixels, 0 keeps its size")
+	logoMargin := flag.Float64("logo-margin", 0, "Modules cleared around the opaque pixels with -logo-plate shape, 0 for half a module, negative for none")
+	logoPosition := flag.String("logo-position", "center", "Logo position: center, auto for the nearest spot hiding only data modules, or x,y modules from the center")
+	logoPlateColor := flag.String("logo-plate-color", "", "Color of the logo plate, in the same forms as -color, transparent when empty")
+	logoBorder := flag.Float64("logo-border", 0, "Width of the ring around the logo plate in modules, 0 for the default, negative for none")
 	isMicro := flag.Bool("micro", false, "IsMicro: true/false")
 	debug := flag.Bool("debug", false, "Debug mode")
+	outPath := flag.String("o", "", "Output file, - for stdout. With several formats each one gets its extension appended. Defaults to qr.<ext>")
+	formatStr := flag.String("format", "svg", "Comma separated output formats: svg, png, pdf, eps, terminal, json, txt, csv, html, dxf, hpgl, stl, zpl, escpos, tikz")
+	modulePx := flag.Int("module-px", 16, "Module size in pixels for svg and png output")
+	svgWidth := flag.Int("width", 0, "Width of svg output in pixels, overrides -module-px")
+	svgHeight := flag.Int("height", 0, "Height of svg output in pixels, overrides -module-px")
+	quietZone := flag.Int("quiet-zone", 4, "Width of the light margin around the symbol, in modules")
+	idPrefix := flag.String("id-prefix", "", "Prefix of the ids in svg output, derived from the data when empty")
+	sprite := flag.Bool("sprite", false, "Write the svg as a <symbol> to reference with <use href=\"#<prefix>code\">")
+	linkLogo := flag.Bool("link-logo", false, "Reference the logo file from svg output instead of embedding it")
+	svgCompact := flag.Bool("svg-compact", false, "Write a compact svg: merged paths for square modules, shared classes for other shapes")
+	precision := flag.Int("precision", 3, "Decimals of the numbers in compact svg output")
+	moduleMM := flag.Float64("module-mm", 1.0, "Module size in millimetres for pdf, eps, dxf, hpgl, stl, zpl, escpos and tikz output")
+	dpi := flag.Int("dpi", 203, "Printer resolution for zpl and escpos output")
+	zplNative := flag.Bool("zpl-native", false, "Use the printer's ^BQ QR Code command instead of a ^GF graphic, not available with -import")
+	cut := flag.Bool("cut", false, "Cut the paper after escpos output")
+	baseMM := flag.Float64("base-mm", 2.0, "Base plate thickness in millimetres for stl output")
+	reliefMM := flag.Float64("relief-mm", 1.0, "Height of the raised modules in millimetres for stl output")
+	stlASCII := flag.Bool("stl-ascii", false, "Write ASCII instead of binary stl")
+	hatch := flag.Float64("hatch", 0, "Hatch line spacing in millimetres for dxf and hpgl output, 0 disables hatching")
+	htmlLayout := flag.String("html-layout", "table", "HTML layout: table (Outlook safe with -shape square), grid")
+	importPath := flag.String("import", "", "Render a module matrix read from a .json, .txt or .csv file instead of encoding data")
+	invert := flag.Bool("invert", false, "Invert terminal output for dark backgrounds")
+	graphicsStr := flag.String("graphics", "auto", "Terminal graphics: auto, none, sixel, kitty")
 
 	// Custom usage message
 	flag.Usage = func() {
@@ -841,44 +904,480 @@ func main() {
 	// Validate shape
 	var shape writer.Shape
 	switch writer.Shape(*shapeStr) {
-	case writer.ShapeSquare, writer.ShapeCircle, writer.ShapeRounded, writer.ShapeSlanted, writer.ShapeSquircle:
+	case writer.ShapeSquare, writer.ShapeCircle, writer.ShapeRounded, writer.ShapeSlanted, writer.ShapeSquircle,
+		writer.ShapeConnected, writer.ShapeLiquid, writer.ShapeHorizontal, writer.ShapeVertical:
 		shape = writer.Shape(*shapeStr)
 	default:
 		shape = writer.ShapeSquare // Fallback or could panic/error
-		fmt.Printf("Warning: unknown shape '%s', defaulting to 'square'\n", *shapeStr)
+		fmt.Fprintf(os.Stderr, "Warning: unknown shape '%s', defaulting to 'square'\n", *shapeStr)
 	}
 
-	// Validate logo path
-	var logo string
-	if *logoPath == true {
-		switch shape {
-		case writer.ShapeCircle:
-			logo = "resources/logo_circle.jpg"
-		default:
-			logo = "resources/logo_square.jpg"
+	// Validate logo options
+	logo := writer.LogoOptions{
+		Href:         *logoPath,
+		RelativeSize: *logoSize,
+		BorderWidth:  *logoBorder,
+		Margin:       *logoMargin,
+	}
+	switch writer.LogoPlate(*logoPlate) {
+	case writer.PlateCircle, writer.PlateSquare, writer.PlateRounded, writer.PlateNone, writer.PlateShape:
+		logo.Plate = writer.LogoPlate(*logoPlate)
+	case "auto":
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: unknown logo plate '%s', following the module shape\n", *logoPlate)
+	}
+	if *logoPlateColor != "" {
+		c, err := writer.ParseColor(*logoPlateColor)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		logo.PlateColor = c
+	}
+	bg, err := writer.ParseColor(*backgroundStr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	var margin color.Color
+	if *quietZoneColor != "" {
+		if margin, err = writer.ParseColor(*quietZoneColor); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	}
+	// A transparent code usually ends up on white paper or a light page
+	contrastBg := bg
+	if !writer.Visible(bg) {
+		contrastBg = color.White
+	}
+	fg, err := moduleColor(*colorStr, logo.Href, contrastBg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if err := prepareLogo(&logo, shape, fg, *logoMask, *logoKey, *logoRecolor, *logoPadding, *logoPx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	switch writer.LogoPlacement(*logoPosition) {
+	case writer.PlaceCenter, writer.PlaceAuto:
+		logo.Placement = writer.LogoPlacement(*logoPosition)
+	default:
+		if _, err := fmt.Sscanf(*logoPosition, "%d,%d", &logo.Offset.X, &logo.Offset.Y); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -logo-position must be center, auto or x,y, not '%s'\n", *logoPosition)
+			os.Exit(1)
 		}
 	}
+	if *logoSize < 0 || *logoSize >= 1 {
+		fmt.Fprintln(os.Stderr, "Error: -logo-size must be between 0 and 1")
+		os.Exit(1)
+	}
+	if *logoSafety <= 0 || *logoSafety > 1 {
+		fmt.Fprintln(os.Stderr, "Error: -logo-safety must be greater than 0 and at most 1")
+		os.Exit(1)
+	}
 
-	// Validate error correction level
-	fmt.Printf("Using error correction level: %s\n", *levelStr)
-	var err_corr_level string
+	// Validate error correction level, auto tries them from the lowest
+	levels := []errcorr{ERR_CORR_L, ERR_CORR_M, ERR_CORR_Q, ERR_CORR_H}
 	switch errcorr(*levelStr) {
 	case ERR_CORR_L, ERR_CORR_M, ERR_CORR_Q, ERR_CORR_H:
-		err_corr_level = *levelStr
+		levels = []errcorr{errcorr(*levelStr)}
+	case "auto":
+		if logo.Href == "" {
+			levels = levels[:1]
+		}
 	default:
-		err_corr_level = "L"
-		fmt.Printf("Warning: unknown error correction level '%s', defaulting to 'L'\n", *levelStr)
+		levels = levels[:1]
+		fmt.Fprintf(os.Stderr, "Warning: unknown error correction level '%s', defaulting to 'L'\n", *levelStr)
 	}
 
 	req := QRRequest{
 		input_data:     data,
 		is_micro:       *isMicro,
-		logo:           logo,
-		err_corr_level: err_corr_level,
+		err_corr_level: string(levels[0]),
 		debug:          *debug,
 	}
 
-	qr := NewQRCode(req)
-	qr.DebugPrint()
-	qr.Draw(shape)
+	var qr *qr
+	var scene *writer.Scene
+	switch {
+	case *importPath != "":
+		if qr, err = importFile(*importPath); err != nil {
+			fmt.Fprintln(os.Stderr, "Error importing matrix:", err)
+			os.Exit(1)
+		}
+		scene = qr.Scene(shape)
+		if logo.Href != "" {
+			err = qr.placeLogo(scene, logo, *logoSafety)
+		}
+	case logo.Href != "":
+		qr, scene, err = encodeWithLogo(req, shape, logo, *logoSafety, levels)
+	default:
+		qr = NewQRCode(req)
+		scene = qr.Scene(shape)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error placing logo:", err)
+		fmt.Fprintln(os.Stderr, "Use a higher -level, a smaller -logo-size, -logo-size 0 for the largest logo that fits, or another -logo-position")
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Using error correction level: %s\n", qr.error_corr_level)
+
+	var graphics writer.Graphics
+	switch writer.Graphics(*graphicsStr) {
+	case writer.GraphicsNone, writer.GraphicsSixel, writer.GraphicsKitty:
+		graphics = writer.Graphics(*graphicsStr)
+	default:
+		graphics = writer.DetectGraphics()
+	}
+
+	layout, err := parseHTMLLayout(*htmlLayout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	// File extension of every format, terminal output always goes to stdout
+	type output struct {
+		renderer writer.Renderer
+		ext      string
+	}
+	outputs := map[string]output{
+		"svg": {writer.SVGRenderer{
+			Scale:     *modulePx,
+			Width:     *svgWidth,
+			Height:    *svgHeight,
+			Compact:   *svgCompact,
+			Precision: *precision,
+			IDPrefix:  *idPrefix,
+			Sprite:    *sprite,
+			LinkLogo:  *linkLogo,
+		}, "svg"},
+		"png":      {writer.PNGRenderer{ModuleSize: *modulePx}, "png"},
+		"pdf":      {writer.PDFRenderer{ModuleSize: *moduleMM}, "pdf"},
+		"eps":      {writer.EPSRenderer{ModuleSize: *moduleMM}, "eps"},
+		"terminal": {writer.TerminalRenderer{Invert: *invert, Graphics: graphics}, ""},
+		"html":     {writer.HTMLRenderer{Layout: layout}, "html"},
+		"dxf":      {writer.DXFRenderer{PlotOptions: writer.PlotOptions{ModuleSize: *moduleMM, HatchSpacing: *hatch}}, "dxf"},
+		"hpgl":     {writer.HPGLRenderer{PlotOptions: writer.PlotOptions{ModuleSize: *moduleMM, HatchSpacing: *hatch}}, "hpgl"},
+		"stl": {writer.STLRenderer{
+			ModuleSize:    *moduleMM,
+			BaseThickness: *baseMM,
+			ReliefHeight:  *reliefMM,
+			ASCII:         *stlASCII,
+		}, "stl"},
+		"zpl":    {writer.ZPLRenderer{DPI: *dpi, ModuleSize: *moduleMM, Native: *zplNative}, "zpl"},
+		"escpos": {writer.ESCPOSRenderer{DPI: *dpi, ModuleSize: *moduleMM, Cut: *cut}, "escpos"},
+		"tikz":   {writer.TikZRenderer{ModuleSize: *moduleMM, Unit: writer.TikZMillimetre}, "tex"},
+	}
+
+	formats := strings.Split(*formatStr, ",")
+	for i := range formats {
+		formats[i] = strings.TrimSpace(formats[i])
+	}
+	known := append(slices.Collect(maps.Keys(outputs)), string(MatrixJSON), string(MatrixText), string(MatrixCSV))
+	if err := checkOutput(*outPath, formats, known); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if slices.Contains(formats, "svg") && !slices.Contains(formats, "terminal") && *outPath != "-" {
+		qr.DebugPrint()
+	}
+	scene.Color = fg
+	scene.Background = bg
+	if *finderCenterColor == "" {
+		*finderCenterColor = *finderColor
+	}
+	for _, component := range []struct {
+		value  string
+		target *color.Color
+	}{
+		{*finderColor, &scene.Colors.FinderOuter},
+		{*finderCenterColor, &scene.Colors.FinderCenter},
+		{*alignmentColor, &scene.Colors.Alignment},
+		{*timingColor, &scene.Colors.Timing},
+		{*dataColor, &scene.Colors.Data},
+	} {
+		if component.value == "" {
+			continue
+		}
+		if *component.target, err = writer.ParseColor(component.value); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	}
+	if scene.Gradient, err = parseGradient(*gradientStr, *gradientColors, *gradientAngle, *gradientCenter, *gradientRadius, *gradientFinders); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	for _, eye := range []struct {
+		flag, value string
+		target      *writer.EyeShape
+	}{
+		{"-eye-frame", *eyeFrame, &scene.EyeFrame},
+		{"-eye-ball", *eyeBall, &scene.EyeBall},
+	} {
+		if *eye.target, err = parseEyeShape(eye.flag, eye.value); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	}
+	scene.QuietZoneColor = margin
+	scene.QuietZone = max(0, *quietZone)
+	if err := checkContrast(scene, *backdropPath, *minContrast, *allowLowContrast); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	for _, format := range formats {
+		var err error
+		switch out := outputs[format]; {
+		case format == string(MatrixJSON), format == string(MatrixText), format == string(MatrixCSV):
+			err = writeOutput(outputPath(*outPath, format, len(formats) > 1), func(w io.Writer) error {
+				return qr.Export(w, MatrixFormat(format))
+			})
+		case out.ext == "":
+			err = out.renderer.Render(os.Stdout, scene)
+		default:
+			err = writeOutput(outputPath(*outPath, out.ext, len(formats) > 1), func(w io.Writer) error {
+				return out.renderer.Render(w, scene)
+			})
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", format, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func importFile(path string) (*qr, error) {
+	format, err := MatrixFormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ImportQRCode(file, format)
+}
+
+// checkOutput refuses formats missing from known, before any file is
+// written, and several formats to stdout, where they would end up
+// concatenated
+func checkOutput(out string, formats, known []string) error {
+	for _, format := range formats {
+		if !slices.Contains(known, format) {
+			return fmt.Errorf("unknown format %q", format)
+		}
+	}
+	if out == "-" && len(formats) > 1 {
+		return fmt.Errorf("-o - accepts a single format")
+	}
+	return nil
+}
+
+// outputPath returns the file written for a format with extension ext: the
+// -o value as is for a single format, with the extension appended for
+// several, and qr.<ext> when -o is not set
+func outputPath(out, ext string, several bool) string {
+	switch {
+	case out == "":
+		return "qr." + ext
+	case out == "-" || !several:
+		return out
+	default:
+		return out + "." + ext
+	}
+}
+
+// creates the file at path, or uses stdout for -, and fills it with write.
+// A file left incomplete by a failed write is removed.
+func writeOutput(path string, write func(io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// moduleColor parses the -color value. auto picks the brand color of the
+// raster logo at href that keeps enough contrast with background.
+func moduleColor(value, href string, background color.Color) (color.Color, error) {
+	if value != "auto" {
+		return writer.ParseColor(value)
+	}
+	if href == "" || writer.IsSVGLogo(href) {
+		return nil, fmt.Errorf("-color auto needs a png, jpeg or gif -logo")
+	}
+	img, err := images.Load(href)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := images.ThemeColor(img, background, autoColorContrast)
+	if !ok {
+		return nil, fmt.Errorf("%s: logo is fully transparent", href)
+	}
+	fmt.Fprintf(os.Stderr, "Using color #%02x%02x%02x from the logo\n", c.R, c.G, c.B)
+	return c, nil
+}
+
+// parseEyeShape checks the value of an -eye-frame or -eye-ball flag
+func parseEyeShape(name, value string) (writer.EyeShape, error) {
+	switch shape := writer.EyeShape(value); shape {
+	case "", writer.EyeSquare, writer.EyeRounded, writer.EyeCircle, writer.EyeLeaf, writer.EyeTeardrop, writer.EyeDotted:
+		return shape, nil
+	}
+	return "", fmt.Errorf("unknown %s shape %q, use square, rounded, circle, leaf, teardrop or dotted", name, value)
+}
+
+// parseHTMLLayout checks the value of the -html-layout flag
+func parseHTMLLayout(value string) (writer.HTMLLayout, error) {
+	switch layout := writer.HTMLLayout(value); layout {
+	case writer.HTMLTable, writer.HTMLGrid:
+		return layout, nil
+	}
+	return "", fmt.Errorf("unknown -html-layout %q, use table or grid", value)
+}
+
+// parseGradient builds the gradient of the -gradient flags, nil for none
+func parseGradient(kind, colors string, angle float64, center string, radius float64, finders bool) (*writer.Gradient, error) {
+	g := &writer.Gradient{Kind: writer.GradientKind(kind), Angle: angle, Radius: radius, Finders: finders}
+	switch g.Kind {
+	case "none":
+		return nil, nil
+	case writer.GradientLinear:
+	case writer.GradientRadial:
+		if _, err := fmt.Sscanf(center, "%g,%g", &g.CenterX, &g.CenterY); err != nil {
+			return nil, fmt.Errorf("invalid gradient center %q, want x,y", center)
+		}
+	default:
+		return nil, fmt.Errorf("unknown gradient %q", kind)
+	}
+	values := splitColors(colors)
+	if len(values) < 2 {
+		return nil, fmt.Errorf("-gradient %s needs at least two -gradient-colors", kind)
+	}
+	for i, value := range values {
+		c, err := writer.ParseColor(value)
+		if err != nil {
+			return nil, err
+		}
+		g.Stops = append(g.Stops, writer.GradientStop{Offset: float64(i) / float64(len(values)-1), Color: c})
+	}
+	return g, nil
+}
+
+// splitColors splits a comma separated list of colors, leaving the commas
+// inside rgb() alone
+func splitColors(list string) []string {
+	var values []string
+	depth, start := 0, 0
+	for i, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				values = append(values, strings.TrimSpace(list[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(list[start:]); last != "" || len(values) > 0 {
+		values = append(values, last)
+	}
+	return values
+}
+
+// checkContrast warns about inverted codes and fails when the contrast of
+// scene is below minContrast, unless allowLow turns that into a warning
+func checkContrast(scene *writer.Scene, backdropPath string, minContrast float64, allowLow bool) error {
+	var backdrop image.Image
+	if backdropPath != "" {
+		var err error
+		if backdrop, err = images.Load(backdropPath); err != nil {
+			return err
+		}
+	}
+	c := writer.CheckContrast(scene, backdrop)
+	if c.Inverted {
+		fmt.Fprintln(os.Stderr, "Warning: the dark modules are lighter than the background, many scanners do not read inverted codes")
+	}
+	if c.Ratio >= minContrast {
+		return nil
+	}
+	err := fmt.Errorf("contrast %.2f:1 between #%02x%02x%02x and #%02x%02x%02x is below -min-contrast %g, scanners may not read the code",
+		c.Ratio, c.Foreground.R, c.Foreground.G, c.Foreground.B, c.Background.R, c.Background.G, c.Background.B, minContrast)
+	if !allowLow {
+		return fmt.Errorf("%w. Use -allow-low-contrast to write it anyway", err)
+	}
+	fmt.Fprintln(os.Stderr, "Warning:", err)
+	return nil
+}
+
+// prepareLogo runs the images pipeline on a raster logo when any of its
+// steps is requested, leaving the result in logo.Image
+func prepareLogo(logo *writer.LogoOptions, shape writer.Shape, fg color.Color, mask, key, recolor string, padding float64, px int) error {
+	opts := images.Options{
+		Recolor: images.Recolor(recolor),
+		Padding: padding,
+		Size:    px,
+		Dark:    fg,
+		Light:   color.White,
+	}
+	switch images.Mask(mask) {
+	case images.MaskNone, images.MaskCircle, images.MaskRounded, images.MaskSquircle:
+		opts.Mask = images.Mask(mask)
+	case "auto":
+		switch shape {
+		case writer.ShapeCircle, writer.ShapeRounded, writer.ShapeSquircle:
+			opts.Mask = images.Mask(shape)
+		}
+	default:
+		return fmt.Errorf("unknown logo mask %q", mask)
+	}
+	switch opts.Recolor {
+	case images.RecolorNone, images.RecolorMono, images.RecolorDuotone:
+	default:
+		return fmt.Errorf("unknown logo recolor %q", recolor)
+	}
+	if padding < 0 || px < 0 {
+		return fmt.Errorf("logo padding and size must not be negative")
+	}
+	if logo.Href == "" || (opts.Mask == "" || opts.Mask == images.MaskNone) && key == "" && opts.Recolor == images.RecolorNone && padding == 0 && px == 0 {
+		return nil
+	}
+	if writer.IsSVGLogo(logo.Href) {
+		return fmt.Errorf("%s: svg logos cannot be masked, keyed, recolored, padded or resized", logo.Href)
+	}
+
+	img, err := images.Load(logo.Href)
+	if err != nil {
+		return err
+	}
+	switch key {
+	case "":
+	case "auto":
+		opts.Key = images.CornerColor(img)
+	default:
+		if opts.Key, err = writer.ParseColor(key); err != nil {
+			return err
+		}
+	}
+	logo.Image = images.Process(img, opts)
+	return nil
 }
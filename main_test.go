@@ -1,6 +1,10 @@
 package main
 
 import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -63,3 +67,85 @@ func TestAlphanumeric(t *testing.T) {
 		t.Errorf("Expected Version 1, got %d", qr.version.Number)
 	}
 }
+
+func TestOutputPath(t *testing.T) {
+	tests := []struct {
+		out, ext string
+		several  bool
+		want     string
+	}{
+		{"", "svg", false, "qr.svg"},
+		{"", "tex", true, "qr.tex"},
+		{"code.svg", "svg", false, "code.svg"},
+		{"code", "svg", true, "code.svg"},
+		{"code", "png", true, "code.png"},
+		{"-", "svg", false, "-"},
+	}
+	for _, tt := range tests {
+		if got := outputPath(tt.out, tt.ext, tt.several); got != tt.want {
+			t.Errorf("outputPath(%q, %q, %v) = %q, want %q", tt.out, tt.ext, tt.several, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	tests := []struct {
+		out     string
+		formats []string
+		ok      bool
+	}{
+		{"-", []string{"svg"}, true},
+		{"-", []string{"svg", "png"}, false},
+		{"code", []string{"svg", "png"}, true},
+		{"", []string{"svg", "png"}, true},
+		{"", []string{"json"}, true},
+		{"", []string{"svg", "pgn"}, false},
+		{"", []string{""}, false},
+	}
+	known := []string{"svg", "png", "json"}
+	for _, tt := range tests {
+		if err := checkOutput(tt.out, tt.formats, known); (err == nil) != tt.ok {
+			t.Errorf("checkOutput(%q, %q): %v", tt.out, tt.formats, err)
+		}
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	write := func(w io.Writer) error {
+		_, err := io.WriteString(w, "symbol")
+		return err
+	}
+
+	path := filepath.Join(t.TempDir(), "qr.svg")
+	if err := writeOutput(path, write); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "symbol" {
+		t.Errorf("file holds %q, %v", data, err)
+	}
+
+	// - writes to stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = writeOutput("-", write)
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(r); string(data) != "symbol" {
+		t.Errorf("stdout got %q", data)
+	}
+
+	failure := errors.New("render failed")
+	if err := writeOutput(path, func(io.Writer) error { return failure }); err != failure {
+		t.Errorf("got %v, want the write error", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("partial file left after a failed write: %v", err)
+	}
+}
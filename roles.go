@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/harogaston/go-mosaic/writer"
+)
+
+// one letter per role, used to store role maps as text
+var roleLetters = map[writer.Role]byte{
+	writer.RoleData:       'd',
+	writer.RoleFinder:     'F',
+	writer.RoleSeparator:  's',
+	writer.RoleTiming:     'T',
+	writer.RoleAlignment:  'A',
+	writer.RoleFormat:     'f',
+	writer.RoleVersion:    'v',
+	writer.RoleDarkModule: 'K',
+}
+
+// roleLetter returns the single character used for r in textual role maps.
+func roleLetter(r writer.Role) byte {
+	return roleLetters[r]
+}
+
+// RoleFromLetter is the inverse of roleLetter.
+func RoleFromLetter(l byte) (writer.Role, error) {
+	for r, letter := range roleLetters {
+		if letter == l {
+			return r, nil
+		}
+	}
+	return writer.RoleData, fmt.Errorf("unknown role letter %q", l)
+}
+
+// computes the role of every module of a symbol of the given version from
+// the position of its function patterns
+func module_roles(versionNumber int, size int) [][]writer.Role {
+	roles := make([][]writer.Role, size)
+	for i := range roles {
+		roles[i] = make([]writer.Role, size)
+		for j := range roles[i] {
+			roles[i][j] = writer.RoleData
+		}
+	}
+	fill := func(row0, col0, rows, cols int, r writer.Role) {
+		for i := row0; i < row0+rows; i++ {
+			for j := col0; j < col0+cols; j++ {
+				roles[i][j] = r
+			}
+		}
+	}
+
+	// separators first, finders are drawn on top of them
+	fill(0, 0, 8, 8, writer.RoleSeparator)
+	fill(0, size-8, 8, 8, writer.RoleSeparator)
+	fill(size-8, 0, 8, 8, writer.RoleSeparator)
+	fill(0, 0, 7, 7, writer.RoleFinder)
+	fill(0, size-7, 7, 7, writer.RoleFinder)
+	fill(size-7, 0, 7, 7, writer.RoleFinder)
+
+	for k := 8; k < size-8; k++ {
+		roles[6][k] = writer.RoleTiming
+		roles[k][6] = writer.RoleTiming
+	}
+
+	for _, ap := range alignment_patterns_positions(versionNumber, size) {
+		fill(ap[0]-2, ap[1]-2, 5, 5, writer.RoleAlignment)
+	}
+
+	// same areas as reserve_format_information_area
+	for j := range size {
+		if j < 6 || j == 7 || j > size-8-1 {
+			roles[8][j] = writer.RoleFormat
+		}
+	}
+	for i := range size {
+		if i < 6 || i > 6 && i < 9 || i > size-8 {
+			roles[i][8] = writer.RoleFormat
+		}
+	}
+	roles[4*versionNumber+9][8] = writer.RoleDarkModule
+
+	if versionNumber >= 7 {
+		fill(0, size-11, 6, 3, writer.RoleVersion)
+		fill(size-11, 0, 3, 6, writer.RoleVersion)
+	}
+	return roles
+}
+
+// Roles returns the role of every module of the symbol
+func (qr *qr) Roles() [][]writer.Role {
+	return module_roles(qr.version.Number, qr.size)
+}
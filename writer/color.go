@@ -0,0 +1,211 @@
+package writer
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SpotColor is a named ink, such as a Pantone reference, together with the
+// CMYK mix used by devices that do not know the ink.
+type SpotColor struct {
+	Name     string
+	Fallback color.CMYK
+}
+
+// RGBA implements color.Color using the CMYK fallback.
+func (s SpotColor) RGBA() (r, g, b, a uint32) {
+	return s.Fallback.RGBA()
+}
+
+// ToCMYK returns the process color components of c and whether c was
+// specified in CMYK (directly or as a spot color fallback).
+func ToCMYK(c color.Color) (color.CMYK, bool) {
+	switch v := c.(type) {
+	case color.CMYK:
+		return v, true
+	case *color.CMYK:
+		return *v, true
+	case SpotColor:
+		return v.Fallback, true
+	case *SpotColor:
+		return v.Fallback, true
+	}
+	return color.CMYKModel.Convert(c).(color.CMYK), false
+}
+
+// ToSpot returns the spot color wrapped by c, if any.
+func ToSpot(c color.Color) (SpotColor, bool) {
+	switch v := c.(type) {
+	case SpotColor:
+		return v, true
+	case *SpotColor:
+		return *v, true
+	}
+	return SpotColor{}, false
+}
+
+// ColorToFill returns the SVG paint value for c. RGB colors become rgb(),
+// CMYK colors device-cmyk(). Spot colors become the device-cmyk() of their
+// fallback: SVG can only name an ink through an ICC named color profile,
+// which the writer does not have, so only the PDF and EPS renderers keep
+// the ink name.
+func ColorToFill(c color.Color) string {
+	if c == nil {
+		return "none"
+	}
+	if cmyk, ok := ToCMYK(c); ok {
+		return fmt.Sprintf("device-cmyk(%s %s %s %s)",
+			unitValue(cmyk.C), unitValue(cmyk.M), unitValue(cmyk.Y), unitValue(cmyk.K))
+	}
+	return rgbFill(c)
+}
+
+// Paint returns the CSS declarations that set property to c. Print colors
+// are preceded by an rgb() declaration so renderers that do not understand
+// device-cmyk() drop the second one and keep the fallback.
+// Transparent colors paint nothing and translucent ones add an opacity.
+func Paint(property string, c color.Color) string {
+	if !Visible(c) {
+		return property + ":none"
+	}
+	paint := fmt.Sprintf("%s:%s", property, rgbFill(c))
+	if _, ok := ToCMYK(c); ok {
+		paint += fmt.Sprintf(";%s:%s", property, ColorToFill(c))
+	}
+	if _, _, _, a := c.RGBA(); a < 0xffff {
+		paint += fmt.Sprintf(";%s-opacity:%s", property, unitValue(uint8(a>>8)))
+	}
+	return paint
+}
+
+// Visible reports whether c is set and not fully transparent.
+func Visible(c color.Color) bool {
+	if c == nil {
+		return false
+	}
+	_, _, _, a := c.RGBA()
+	return a != 0
+}
+
+func rgbFill(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("rgb(%d %d %d)", n.R, n.G, n.B)
+}
+
+// ParseColor parses a color written as #rgb, #rgba, #rrggbb or #rrggbbaa,
+// as rgb(r, g, b) or rgba(r, g, b, a) with 0-255 or percent components and
+// an alpha from 0 to 1 or a percentage, as a CSS color name, or as
+// transparent. Print colors are written cmyk(c, m, y, k), or
+// device-cmyk(c m y k) as in CSS, and spot(name, c, m, y, k) for a named
+// ink with its CMYK fallback, with components from 0 to 1 or percentages.
+func ParseColor(value string) (color.Color, error) {
+	v := strings.ToLower(strings.TrimSpace(value))
+	if c, ok := colorNames[v]; ok {
+		return c, nil
+	}
+	invalid := fmt.Errorf("invalid color %q", value)
+	var n color.NRGBA
+	switch {
+	case (strings.HasPrefix(v, "cmyk(") || strings.HasPrefix(v, "device-cmyk(")) && strings.HasSuffix(v, ")"):
+		cmyk, ok := parseCMYK(v[strings.IndexByte(v, '(')+1 : len(v)-1])
+		if !ok {
+			return nil, invalid
+		}
+		return cmyk, nil
+	case strings.HasPrefix(v, "spot(") && strings.HasSuffix(v, ")"):
+		// The ink name keeps its case, Pantone references are upper case
+		args := strings.TrimSpace(value)
+		args = args[len("spot(") : len(args)-1]
+		name, components, found := strings.Cut(args, ",")
+		name = strings.TrimSpace(name)
+		cmyk, ok := parseCMYK(components)
+		if !found || name == "" || !ok {
+			return nil, invalid
+		}
+		return SpotColor{Name: name, Fallback: cmyk}, nil
+	case v == "transparent":
+		return color.NRGBA{}, nil
+	case strings.HasPrefix(v, "#"):
+		hex := v[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			long := make([]byte, 0, 2*len(hex))
+			for i := range len(hex) {
+				long = append(long, hex[i], hex[i])
+			}
+			hex = string(long)
+		}
+		if len(hex) == 6 {
+			hex += "ff"
+		}
+		if len(hex) != 8 {
+			return nil, invalid
+		}
+		if _, err := fmt.Sscanf(hex, "%02x%02x%02x%02x", &n.R, &n.G, &n.B, &n.A); err != nil {
+			return nil, invalid
+		}
+	case (strings.HasPrefix(v, "rgb(") || strings.HasPrefix(v, "rgba(")) && strings.HasSuffix(v, ")"):
+		args := v[strings.IndexByte(v, '(')+1 : len(v)-1]
+		fields := strings.Fields(strings.NewReplacer(",", " ", "/", " ").Replace(args))
+		if len(fields) != 3 && len(fields) != 4 {
+			return nil, invalid
+		}
+		fields = append(fields, "1")
+		channels := []*uint8{&n.R, &n.G, &n.B, &n.A}
+		for i, channel := range channels {
+			field, percent := strings.CutSuffix(fields[i], "%")
+			f, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, invalid
+			}
+			if percent {
+				f /= 100
+			}
+			if percent || i == 3 {
+				f *= 255
+			}
+			if f < 0 || f > 255 {
+				return nil, invalid
+			}
+			*channel = uint8(math.Round(f))
+		}
+	default:
+		return nil, invalid
+	}
+	if n.A == 0xff {
+		return color.RGBA{n.R, n.G, n.B, 0xff}, nil
+	}
+	return n, nil
+}
+
+// parseCMYK parses four components separated by commas or spaces, each
+// from 0 to 1 or a percentage
+func parseCMYK(args string) (color.CMYK, bool) {
+	var cmyk color.CMYK
+	fields := strings.Fields(strings.ReplaceAll(args, ",", " "))
+	if len(fields) != 4 {
+		return cmyk, false
+	}
+	for i, channel := range []*uint8{&cmyk.C, &cmyk.M, &cmyk.Y, &cmyk.K} {
+		field, percent := strings.CutSuffix(fields[i], "%")
+		f, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return cmyk, false
+		}
+		if percent {
+			f /= 100
+		}
+		if f < 0 || f > 1 {
+			return cmyk, false
+		}
+		*channel = uint8(math.Round(f * 255))
+	}
+	return cmyk, true
+}
+
+// unitValue formats a 0-255 component as a number in [0, 1].
+func unitValue(v uint8) string {
+	return fmt.Sprintf("%.4g", float64(v)/255.)
+}
@@ -0,0 +1,149 @@
+package writer
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  color.Color
+	}{
+		{"#0a6400", color.RGBA{10, 100, 0, 0xff}},
+		{"#FA0", color.RGBA{0xff, 0xaa, 0, 0xff}},
+		{"#ff000080", color.NRGBA{0xff, 0, 0, 0x80}},
+		{"#f008", color.NRGBA{0xff, 0, 0, 0x88}},
+		{"rgb(10, 100, 0)", color.RGBA{10, 100, 0, 0xff}},
+		{"rgb(100% 0% 50% / 0.5)", color.NRGBA{0xff, 0, 0x80, 0x80}},
+		{"rgba(0,0,128,0.8)", color.NRGBA{0, 0, 0x80, 0xcc}},
+		{"RebeccaPurple", color.RGBA{0x66, 0x33, 0x99, 0xff}},
+		{"gray", color.RGBA{0x80, 0x80, 0x80, 0xff}},
+		{"transparent", color.NRGBA{}},
+		{"cmyk(0, 100%, 80%, 0)", color.CMYK{0, 0xff, 0xcc, 0}},
+		{"device-cmyk(0 0 0 1)", color.CMYK{0, 0, 0, 0xff}},
+		{"spot(PANTONE 185 C, 0 1 0.8 0)", SpotColor{Name: "PANTONE 185 C", Fallback: color.CMYK{0, 0xff, 0xcc, 0}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.value)
+		if err != nil {
+			t.Errorf("ParseColor(%q): %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+	for _, value := range []string{"", "#12", "#12345g", "rgb(1,2)", "rgb(300,0,0)", "hsl(0,0%,0%)", "nosuch",
+		"cmyk(0,0,0)", "cmyk(0 0 0 2)", "cmyk(0 0 0 101%)", "spot(, 0 0 0 1)", "spot(ink)", "spot(ink, 0 0 1)"} {
+		if _, err := ParseColor(value); err == nil {
+			t.Errorf("ParseColor(%q) accepted", value)
+		}
+	}
+}
+
+var pantone = SpotColor{Name: "PANTONE 185 C", Fallback: color.CMYK{0, 0xff, 0xcc, 0}}
+
+func TestToCMYK(t *testing.T) {
+	tests := []struct {
+		c     color.Color
+		want  color.CMYK
+		given bool
+	}{
+		{color.CMYK{0x10, 0x20, 0x30, 0x40}, color.CMYK{0x10, 0x20, 0x30, 0x40}, true},
+		{&color.CMYK{0, 0, 0, 0xff}, color.CMYK{0, 0, 0, 0xff}, true},
+		{pantone, pantone.Fallback, true},
+		{&pantone, pantone.Fallback, true},
+		{color.RGBA{0xff, 0, 0, 0xff}, color.CMYK{0, 0xff, 0xff, 0}, false},
+	}
+	for _, tt := range tests {
+		got, given := ToCMYK(tt.c)
+		if got != tt.want || given != tt.given {
+			t.Errorf("ToCMYK(%v) = %v, %t, want %v, %t", tt.c, got, given, tt.want, tt.given)
+		}
+	}
+}
+
+func TestToSpot(t *testing.T) {
+	tests := []struct {
+		c    color.Color
+		want SpotColor
+		ok   bool
+	}{
+		{pantone, pantone, true},
+		{&pantone, pantone, true},
+		{pantone.Fallback, SpotColor{}, false},
+		{color.Black, SpotColor{}, false},
+	}
+	for _, tt := range tests {
+		if got, ok := ToSpot(tt.c); got != tt.want || ok != tt.ok {
+			t.Errorf("ToSpot(%v) = %v, %t, want %v, %t", tt.c, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestColorToFill(t *testing.T) {
+	tests := []struct {
+		c    color.Color
+		want string
+	}{
+		{nil, "none"},
+		{color.RGBA{10, 100, 0, 0xff}, "rgb(10 100 0)"},
+		{color.CMYK{0, 0xff, 0xcc, 0}, "device-cmyk(0 1 0.8 0)"},
+		{pantone, "device-cmyk(0 1 0.8 0)"},
+	}
+	for _, tt := range tests {
+		if got := ColorToFill(tt.c); got != tt.want {
+			t.Errorf("ColorToFill(%v) = %q, want %q", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestPaint(t *testing.T) {
+	tests := []struct {
+		c    color.Color
+		want string
+	}{
+		{color.RGBA{10, 100, 0, 0xff}, "fill:rgb(10 100 0)"},
+		{color.NRGBA{0, 0, 0x80, 0xcc}, "fill:rgb(0 0 128);fill-opacity:0.8"},
+		{color.NRGBA{0xff, 0xff, 0xff, 0}, "fill:none"},
+		{nil, "fill:none"},
+		{color.CMYK{0, 0xff, 0xcc, 0}, "fill:rgb(255 0 51);fill:device-cmyk(0 1 0.8 0)"},
+		{pantone, "fill:rgb(255 0 51);fill:device-cmyk(0 1 0.8 0)"},
+	}
+	for _, tt := range tests {
+		if got := Paint("fill", tt.c); got != tt.want {
+			t.Errorf("Paint(%v) = %q, want %q", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestTransparentBackground(t *testing.T) {
+	s := blankScene(21)
+	s.Modules[10][10].Dark = true
+	s.Background = color.Transparent
+	var buf bytes.Buffer
+	if err := (SVGRenderer{Scale: 1}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	// Only the square module is a rect, nothing covers the page behind the
+	// code and the finder holes are masked out instead of painted
+	if strings.Count(out, "<rect") != 1 {
+		t.Errorf("background rect drawn:\n%s", out)
+	}
+	if !strings.Contains(out, `mask="url(#`) {
+		t.Errorf("finder patterns are not masked:\n%s", out)
+	}
+
+	s.QuietZoneColor = color.RGBA{0xff, 0xd7, 0, 0xff}
+	buf.Reset()
+	if err := (SVGRenderer{Scale: 1}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "fill:rgb(255 215 0);fill-rule:evenodd") {
+		t.Errorf("quiet zone frame missing:\n%s", buf.String())
+	}
+}
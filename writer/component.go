@@ -0,0 +1,114 @@
+package writer
+
+import (
+	"image/color"
+	"slices"
+)
+
+// Component is a part of the symbol that can be painted with a color of
+// its own.
+type Component string
+
+const (
+	ComponentData         Component = "data"
+	ComponentTiming       Component = "timing"
+	ComponentFinderOuter  Component = "finder-outer"
+	ComponentFinderCenter Component = "finder-center"
+	ComponentAlignment    Component = "alignment"
+	// ComponentOther holds the format and version information and the dark
+	// module, which always use Scene.Color or the gradient.
+	ComponentOther Component = "other"
+)
+
+// ComponentColors overrides Scene.Color, and the gradient, for parts of
+// the symbol. Nil fields keep the scene colors.
+type ComponentColors struct {
+	Data   color.Color
+	Timing color.Color
+	// FinderOuter paints the 7 module ring of the finder patterns and
+	// FinderCenter their 3 module center.
+	FinderOuter  color.Color
+	FinderCenter color.Color
+	// Alignment paints both rings of the alignment patterns.
+	Alignment color.Color
+}
+
+// of returns the color set for c, nil when there is none
+func (cc ComponentColors) of(c Component) color.Color {
+	switch c {
+	case ComponentData:
+		return cc.Data
+	case ComponentTiming:
+		return cc.Timing
+	case ComponentFinderOuter:
+		return cc.FinderOuter
+	case ComponentFinderCenter:
+		return cc.FinderCenter
+	case ComponentAlignment:
+		return cc.Alignment
+	}
+	return nil
+}
+
+// component returns the part of the symbol the module at column x and row
+// y belongs to, according to its role
+func (s *Scene) component(x, y int) Component {
+	switch s.Modules[y][x].Role {
+	case RoleData:
+		return ComponentData
+	case RoleTiming:
+		return ComponentTiming
+	case RoleAlignment:
+		return ComponentAlignment
+	case RoleFinder:
+		for _, f := range s.Finders {
+			if dx, dy := x-f[0], y-f[1]; dx >= 2 && dx <= 4 && dy >= 2 && dy <= 4 {
+				return ComponentFinderCenter
+			}
+		}
+		return ComponentFinderOuter
+	}
+	return ComponentOther
+}
+
+// fill returns the flat color of component c, or nil when the gradient
+// paints it
+func (s *Scene) fill(c Component) color.Color {
+	if override := s.Colors.of(c); override != nil {
+		return override
+	}
+	if g := s.Gradient; g != nil {
+		switch c {
+		case ComponentFinderOuter, ComponentFinderCenter, ComponentAlignment:
+			if !g.Finders {
+				return s.Color
+			}
+		}
+		return nil
+	}
+	return s.Color
+}
+
+// moduleFill returns the flat color of the module at column x and row y,
+// or nil when the gradient paints it
+func (s *Scene) moduleFill(x, y int) color.Color {
+	return s.fill(s.component(x, y))
+}
+
+// moduleFills returns the distinct fills of the dark modules not hidden by
+// the logo, in the order they first appear, nil standing for the gradient.
+// Finder and alignment modules only count when overlays is set.
+func (s *Scene) moduleFills(overlays bool) []color.Color {
+	var fills []color.Color
+	for y, row := range s.Modules {
+		for x, m := range row {
+			if !m.Dark || s.Cleared(x, y) || (!overlays && s.IsOverlay(x, y)) {
+				continue
+			}
+			if fill := s.moduleFill(x, y); !slices.Contains(fills, fill) {
+				fills = append(fills, fill)
+			}
+		}
+	}
+	return fills
+}
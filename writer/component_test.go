@@ -0,0 +1,55 @@
+package writer
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestComponentFill(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	s := blankScene(21)
+	s.Color = color.Black
+	s.Colors = ComponentColors{FinderOuter: red}
+	for _, m := range [][2]int{{0, 0}, {3, 3}, {6, 1}, {16, 0}} {
+		s.Modules[m[1]][m[0]].Role = RoleFinder
+	}
+	s.Modules[6][8].Role = RoleTiming
+
+	tests := []struct {
+		x, y      int
+		component Component
+		fill      color.Color
+	}{
+		{0, 0, ComponentFinderOuter, red},
+		{3, 3, ComponentFinderCenter, color.Black},
+		{6, 1, ComponentFinderOuter, red},
+		{16, 0, ComponentFinderOuter, red},
+		{8, 6, ComponentTiming, color.Black},
+		{10, 10, ComponentData, color.Black},
+	}
+	for _, tt := range tests {
+		if c := s.component(tt.x, tt.y); c != tt.component {
+			t.Errorf("(%d, %d) is %s, want %s", tt.x, tt.y, c, tt.component)
+		}
+		if fill := s.moduleFill(tt.x, tt.y); fill != tt.fill {
+			t.Errorf("(%d, %d) filled with %v, want %v", tt.x, tt.y, fill, tt.fill)
+		}
+	}
+
+	// The gradient paints what has no color of its own, and the finder
+	// patterns only when asked to
+	s.Gradient = &Gradient{Stops: []GradientStop{{0, color.Black}, {1, color.White}}}
+	if fill := s.fill(ComponentData); fill != nil {
+		t.Errorf("data with a gradient filled with %v", fill)
+	}
+	if fill := s.fill(ComponentFinderCenter); fill != color.Black {
+		t.Errorf("finder center filled with %v", fill)
+	}
+	s.Gradient.Finders = true
+	if fill := s.fill(ComponentFinderCenter); fill != nil {
+		t.Errorf("finder center with the gradient filled with %v", fill)
+	}
+	if fill := s.fill(ComponentFinderOuter); fill != red {
+		t.Errorf("finder ring with a color of its own filled with %v", fill)
+	}
+}
@@ -0,0 +1,139 @@
+package writer
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// inset of the lines of ShapeHorizontal and ShapeVertical from the sides
+// of their modules, which keeps a gap between neighboring lines
+const lineInset = 0.1
+
+// connectedPart is a piece of a connected shape. It is either a rectangle
+// with rounded corners over a dark module, radii clockwise from the top
+// left, or a fillet: the concave corner at (x, y) of a light module
+// between two dark ones, reaching width and height, signed, into it.
+type connectedPart struct {
+	x, y, width, height float64
+	radii               [4]float64
+	fillet              bool
+}
+
+// connectedParts returns the pieces of the modules painted with fill, for
+// a connected shape. Modules hidden by the logo, and the finder and
+// alignment patterns when overlays draw them, count as light.
+func (s *Scene) connectedParts(fill color.Color, overlays bool) []connectedPart {
+	dim := s.Size()
+	dark := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < dim && y < dim &&
+			s.Dark(x, y) && !s.Cleared(x, y) && (!overlays || !s.IsOverlay(x, y))
+	}
+	// Corners clockwise from the top left, as the directions of their
+	// horizontal and vertical neighbors
+	corners := [4][2]int{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}}
+	var parts []connectedPart
+	for y := range dim {
+		for x := range dim {
+			if !dark(x, y) {
+				if s.Shape != ShapeLiquid || s.Cleared(x, y) || (overlays && s.IsOverlay(x, y)) {
+					continue
+				}
+				// Fill the corners between two dark neighbors of the same
+				// color
+				for _, c := range corners {
+					if dark(x+c[0], y) && dark(x, y+c[1]) &&
+						s.moduleFill(x+c[0], y) == fill && s.moduleFill(x, y+c[1]) == fill {
+						cx, cy := float64(x+max(c[0], 0)), float64(y+max(c[1], 0))
+						parts = append(parts, connectedPart{
+							x: cx, y: cy, width: -0.5 * float64(c[0]), height: -0.5 * float64(c[1]), fillet: true,
+						})
+					}
+				}
+				continue
+			}
+			if s.moduleFill(x, y) != fill {
+				continue
+			}
+			part := connectedPart{x: float64(x), y: float64(y), width: 1, height: 1}
+			for i, c := range corners {
+				var round bool
+				switch s.Shape {
+				case ShapeHorizontal:
+					round = !dark(x+c[0], y)
+				case ShapeVertical:
+					round = !dark(x, y+c[1])
+				case ShapeLiquid:
+					round = !dark(x+c[0], y) && !dark(x, y+c[1]) && !dark(x+c[0], y+c[1])
+				default:
+					round = !dark(x+c[0], y) && !dark(x, y+c[1])
+				}
+				if round {
+					part.radii[i] = 0.5
+				}
+			}
+			switch s.Shape {
+			case ShapeHorizontal:
+				part.y += lineInset
+				part.height -= 2 * lineInset
+			case ShapeVertical:
+				part.x += lineInset
+				part.width -= 2 * lineInset
+			}
+			if s.Shape.lines() {
+				for i, r := range part.radii {
+					part.radii[i] = math.Min(r, 0.5-lineInset)
+				}
+			}
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// outline returns the polygon of p moved by (dx, dy). A fillet starts at
+// its corner, from which every vertex is visible.
+func (p connectedPart) outline(dx, dy float64) [][2]float64 {
+	if !p.fillet {
+		return cornerOutline(p.x+dx, p.y+dy, p.width, p.height, p.radii)
+	}
+	x, y := p.x+dx, p.y+dy
+	perCorner := paintSegments / 4
+	outline := [][2]float64{{x, y}}
+	for i := range perCorner + 1 {
+		a := math.Pi / 2 * float64(i) / float64(perCorner)
+		outline = append(outline, [2]float64{
+			x + p.width - p.width*math.Sin(a),
+			y + p.height - p.height*math.Cos(a),
+		})
+	}
+	return outline
+}
+
+// writePath writes the path data of p
+func (p connectedPart) writePath(b *strings.Builder, num func(float64) string) {
+	if !p.fillet {
+		writeCornerPath(b, p.x, p.y, p.width, p.height, p.radii, num)
+		return
+	}
+	// The arc bends toward the corner, clockwise when the fillet reaches
+	// right and up or left and down
+	sweep := 0
+	if p.width*p.height < 0 {
+		sweep = 1
+	}
+	r := math.Abs(p.width)
+	fmt.Fprintf(b, "M%s %sH%sA%s %s 0 0 %d %s %sZ",
+		num(p.x), num(p.y), num(p.x+p.width), num(r), num(r), sweep, num(p.x), num(p.y+p.height))
+}
+
+// connectedPath returns the path data of the modules painted with fill,
+// for a connected shape
+func connectedPath(s *Scene, fill color.Color, overlays bool, num func(float64) string) string {
+	var b strings.Builder
+	for _, part := range s.connectedParts(fill, overlays) {
+		part.writePath(&b, num)
+	}
+	return b.String()
+}
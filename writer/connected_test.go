@@ -0,0 +1,50 @@
+package writer
+
+import "testing"
+
+func TestConnectedParts(t *testing.T) {
+	s := sceneFromRows(
+		"##..",
+		"..#.",
+		"....",
+		"....",
+	)
+	tests := []struct {
+		shape Shape
+		want  []connectedPart
+	}{
+		// Rounded outer corners only, the diagonal neighbors stay apart
+		{ShapeConnected, []connectedPart{
+			{x: 0, y: 0, width: 1, height: 1, radii: [4]float64{0.5, 0, 0, 0.5}},
+			{x: 1, y: 0, width: 1, height: 1, radii: [4]float64{0, 0.5, 0.5, 0}},
+			{x: 2, y: 1, width: 1, height: 1, radii: [4]float64{0.5, 0.5, 0.5, 0.5}},
+		}},
+		// The corners facing the diagonal neighbor stay square, with
+		// fillets in the light modules on both sides of the join
+		{ShapeLiquid, []connectedPart{
+			{x: 0, y: 0, width: 1, height: 1, radii: [4]float64{0.5, 0, 0, 0.5}},
+			{x: 1, y: 0, width: 1, height: 1, radii: [4]float64{0, 0.5, 0, 0}},
+			{x: 2, y: 1, width: 0.5, height: -0.5, fillet: true},
+			{x: 2, y: 1, width: -0.5, height: 0.5, fillet: true},
+			{x: 2, y: 1, width: 1, height: 1, radii: [4]float64{0, 0.5, 0.5, 0.5}},
+		}},
+		{ShapeHorizontal, []connectedPart{
+			{x: 0, y: 0.1, width: 1, height: 0.8, radii: [4]float64{0.4, 0, 0, 0.4}},
+			{x: 1, y: 0.1, width: 1, height: 0.8, radii: [4]float64{0, 0.4, 0.4, 0}},
+			{x: 2, y: 1.1, width: 1, height: 0.8, radii: [4]float64{0.4, 0.4, 0.4, 0.4}},
+		}},
+	}
+	for _, tt := range tests {
+		s.Shape = tt.shape
+		got := s.connectedParts(s.Color, false)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d parts %v, want %d", tt.shape, len(got), got, len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: part %d is %+v, want %+v", tt.shape, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package writer
+
+import (
+	"image"
+)
+
+// traceContours returns the outlines of the connected regions of a size x
+// size grid where dark is true, as closed polygons on the module grid (one
+// unit per module, y pointing down). Outer boundaries run clockwise and holes counterclockwise,
+// so the polygons can be filled with either the nonzero or the even-odd
+// rule. Modules touching only at a corner belong to different regions.
+// Consecutive collinear vertices are merged.
+func traceContours(size int, dark func(x, y int) bool) [][]image.Point {
+	// Directed boundary edges, keeping the dark module on the right
+	type edge struct{ from, to image.Point }
+	outgoing := map[image.Point][]int{}
+	var edges []edge
+	add := func(x0, y0, x1, y1 int) {
+		from := image.Pt(x0, y0)
+		outgoing[from] = append(outgoing[from], len(edges))
+		edges = append(edges, edge{from, image.Pt(x1, y1)})
+	}
+	for y := range size {
+		for x := range size {
+			if !dark(x, y) {
+				continue
+			}
+			if !dark(x, y-1) {
+				add(x, y, x+1, y)
+			}
+			if !dark(x+1, y) {
+				add(x+1, y, x+1, y+1)
+			}
+			if !dark(x, y+1) {
+				add(x+1, y+1, x, y+1)
+			}
+			if !dark(x-1, y) {
+				add(x, y+1, x, y)
+			}
+		}
+	}
+
+	used := make([]bool, len(edges))
+	var contours [][]image.Point
+	for start := range edges {
+		if used[start] {
+			continue
+		}
+		var points []image.Point
+		current := start
+		for !used[current] {
+			used[current] = true
+			e := edges[current]
+			points = append(points, e.from)
+			dir := e.to.Sub(e.from)
+
+			// Where two regions touch diagonally the vertex has two exits;
+			// turning right keeps the regions apart.
+			next := -1
+			for _, candidate := range outgoing[e.to] {
+				if used[candidate] && candidate != start {
+					continue
+				}
+				next = candidate
+				cdir := edges[candidate].to.Sub(edges[candidate].from)
+				if cdir == image.Pt(-dir.Y, dir.X) {
+					break
+				}
+			}
+			if next < 0 {
+				break
+			}
+			current = next
+		}
+		contours = append(contours, simplifyContour(points))
+	}
+	return contours
+}
+
+// simplifyContour drops vertices lying on a straight segment
+func simplifyContour(points []image.Point) []image.Point {
+	n := len(points)
+	var simplified []image.Point
+	for i, p := range points {
+		prev := points[(i+n-1)%n]
+		next := points[(i+1)%n]
+		if sameDirection(p.Sub(prev), next.Sub(p)) {
+			continue
+		}
+		simplified = append(simplified, p)
+	}
+	return simplified
+}
+
+func sameDirection(a, b image.Point) bool {
+	return sign(a.X) == sign(b.X) && sign(a.Y) == sign(b.Y)
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// darkRuns returns the [start, end) columns of the horizontal runs of dark
+// modules in row
+func darkRuns(row []Module) [][2]int {
+	var runs [][2]int
+	for x, m := range row {
+		if !m.Dark {
+			continue
+		}
+		if n := len(runs); n > 0 && runs[n-1][1] == x {
+			runs[n-1][1] = x + 1
+			continue
+		}
+		runs = append(runs, [2]int{x, x + 1})
+	}
+	return runs
+}
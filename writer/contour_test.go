@@ -0,0 +1,75 @@
+package writer
+
+import (
+	"image"
+	"testing"
+)
+
+func sceneFromRows(rows ...string) *Scene {
+	modules := make([][]Module, len(rows))
+	for y, row := range rows {
+		modules[y] = make([]Module, len(row))
+		for x := range len(row) {
+			modules[y][x] = Module{Dark: row[x] == '#', Role: RoleData}
+		}
+	}
+	return NewScene(modules, nil, ShapeSquare)
+}
+
+// signed area of a polygon, positive for clockwise contours (y down)
+func contourArea(points []image.Point) int {
+	area := 0
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		area += p.X*q.Y - q.X*p.Y
+	}
+	return area / 2
+}
+
+func TestTraceContours(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     []string
+		contours int
+		area     int
+	}{
+		{"single module", []string{"#"}, 1, 1},
+		{"bar", []string{"...", "###", "..."}, 1, 3},
+		{"ring with hole", []string{"###", "#.#", "###"}, 2, 8},
+		{"diagonal neighbors are separate", []string{"#.", ".#"}, 2, 2},
+		{"finder pattern", []string{
+			"#######",
+			"#.....#",
+			"#.###.#",
+			"#.###.#",
+			"#.###.#",
+			"#.....#",
+			"#######",
+		}, 3, 33},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := sceneFromRows(tt.rows...)
+			contours := traceContours(s.Size(), s.Dark)
+			if len(contours) != tt.contours {
+				t.Errorf("got %d contours, expected %d", len(contours), tt.contours)
+			}
+			area := 0
+			for _, c := range contours {
+				area += contourArea(c)
+			}
+			if area != tt.area {
+				t.Errorf("got area %d, expected %d", area, tt.area)
+			}
+		})
+	}
+}
+
+func TestSimplifyContour(t *testing.T) {
+	s := sceneFromRows("###", "###", "...")
+	contours := traceContours(s.Size(), s.Dark)
+	if len(contours) != 1 || len(contours[0]) != 4 {
+		t.Errorf("expected a single rectangle, got %v", contours)
+	}
+}
@@ -0,0 +1,114 @@
+package writer
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+
+	"github.com/harogaston/go-mosaic/images"
+)
+
+// DefaultMinContrast is the lowest WCAG contrast ratio between the dark and
+// light modules that phone scanners read reliably under poor lighting.
+const DefaultMinContrast = 3.0
+
+// backdrop swatches taken into account by CheckContrast
+const backdropColors = 8
+
+// Contrast is the result of CheckContrast for the least readable pair of a
+// foreground color and a color behind the symbol.
+type Contrast struct {
+	// Ratio is the WCAG contrast ratio, from 1 to 21.
+	Ratio float64
+	// LuminanceDiff is the difference of the relative luminances, from 0
+	// to 1, negative when the dark modules are the lighter ones.
+	LuminanceDiff float64
+	// Foreground and Background are the colors of the pair as they appear
+	// on the page, after compositing translucent colors.
+	Foreground color.NRGBA
+	Background color.NRGBA
+	// Inverted reports that the dark modules are lighter than the light
+	// ones for some pair, which many scanners do not read.
+	Inverted bool
+}
+
+// CheckContrast measures how well the dark modules of s stand out. Every
+// foreground color is compared with the background and the quiet zone
+// colors, translucent colors being composited over the dominant colors of
+// backdrop, the image the code is printed on, or over white when backdrop is
+// nil. It returns the pair with the lowest contrast ratio.
+func CheckContrast(s *Scene, backdrop image.Image) Contrast {
+	page := []color.NRGBA{{0xff, 0xff, 0xff, 0xff}}
+	if backdrop != nil {
+		if swatches := images.Palette(backdrop, backdropColors); len(swatches) > 0 {
+			page = page[:0]
+			for _, swatch := range swatches {
+				page = append(page, swatch.Color)
+			}
+		}
+	}
+
+	lights := []color.Color{s.Background}
+	if s.QuietZoneColor != nil {
+		lights = append(lights, s.QuietZoneColor)
+	}
+	var behind []color.NRGBA
+	for _, light := range lights {
+		for _, p := range page {
+			behind = append(behind, over(light, p))
+		}
+	}
+
+	worst := Contrast{Ratio: math.Inf(1)}
+	inverted := false
+	for _, dark := range s.foregroundColors() {
+		for _, b := range behind {
+			fg := over(dark, b)
+			inverted = inverted || images.Luminance(fg) > images.Luminance(b)
+			if ratio := images.ContrastRatio(fg, b); ratio < worst.Ratio {
+				worst = Contrast{
+					Ratio:         ratio,
+					LuminanceDiff: images.Luminance(b) - images.Luminance(fg),
+					Foreground:    fg,
+					Background:    b,
+				}
+			}
+		}
+	}
+	worst.Inverted = inverted
+	return worst
+}
+
+// foregroundColors returns the colors the dark modules are painted with,
+// every stop of the gradient included
+func (s *Scene) foregroundColors() []color.Color {
+	var colors []color.Color
+	gradient := false
+	for _, c := range []Component{ComponentData, ComponentTiming, ComponentFinderOuter, ComponentFinderCenter, ComponentAlignment, ComponentOther} {
+		if fill := s.fill(c); fill == nil {
+			gradient = true
+		} else if !slices.Contains(colors, fill) {
+			colors = append(colors, fill)
+		}
+	}
+	if gradient {
+		for _, stop := range s.Gradient.stops() {
+			colors = append(colors, stop.Color)
+		}
+	}
+	return colors
+}
+
+// over composites c over the opaque color below
+func over(c color.Color, below color.NRGBA) color.NRGBA {
+	if c == nil {
+		return below
+	}
+	top := color.NRGBAModel.Convert(c).(color.NRGBA)
+	a := float64(top.A) / 0xff
+	mix := func(t, b uint8) uint8 {
+		return uint8(math.Round(float64(t)*a + float64(b)*(1-a)))
+	}
+	return color.NRGBA{mix(top.R, below.R), mix(top.G, below.G), mix(top.B, below.B), 0xff}
+}
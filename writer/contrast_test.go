@@ -0,0 +1,49 @@
+package writer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestCheckContrast(t *testing.T) {
+	black := color.NRGBA{A: 0xff}
+	white := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	tests := []struct {
+		name       string
+		fg, bg, qz color.Color
+		backdrop   color.Color
+		ratio      float64
+		inverted   bool
+		background color.NRGBA
+	}{
+		{name: "black on white", fg: black, bg: white, ratio: 21, background: white},
+		{name: "inverted", fg: white, bg: black, ratio: 21, inverted: true, background: black},
+		{name: "pale yellow", fg: color.RGBA{0xff, 0xff, 0x99, 0xff}, bg: white, ratio: 1.05, background: white},
+		{name: "transparent over white page", fg: black, bg: color.Transparent, ratio: 21, background: white},
+		{name: "transparent over dark backdrop", fg: black, bg: color.Transparent, backdrop: color.RGBA{0x20, 0x20, 0x20, 0xff}, ratio: 1.29, background: color.NRGBA{0x20, 0x20, 0x20, 0xff}},
+		{name: "dark quiet zone", fg: black, bg: white, qz: color.RGBA{0x40, 0x40, 0x40, 0xff}, ratio: 2.03, background: color.NRGBA{0x40, 0x40, 0x40, 0xff}},
+	}
+	for _, tt := range tests {
+		s := blankScene(21)
+		s.Color, s.Background, s.QuietZoneColor = tt.fg, tt.bg, tt.qz
+		var c Contrast
+		if tt.backdrop != nil {
+			backdrop := image.NewRGBA(image.Rect(0, 0, 4, 4))
+			draw.Draw(backdrop, backdrop.Rect, image.NewUniform(tt.backdrop), image.Point{}, draw.Src)
+			c = CheckContrast(s, backdrop)
+		} else {
+			c = CheckContrast(s, nil)
+		}
+		if c.Ratio < tt.ratio-0.01 || c.Ratio > tt.ratio+0.01 {
+			t.Errorf("%s: ratio %.3f, want %.2f", tt.name, c.Ratio, tt.ratio)
+		}
+		if c.Inverted != tt.inverted {
+			t.Errorf("%s: inverted %v", tt.name, c.Inverted)
+		}
+		if c.Background != tt.background {
+			t.Errorf("%s: background %v, want %v", tt.name, c.Background, tt.background)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package writer
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// alpha from which a logo pixel is painted in EPS output
+const epsMaskAlpha = 0x80
+
+// EPSRenderer writes an Encapsulated PostScript file the size of the
+// symbol with its quiet zone, for print workflows that place EPS artwork.
+// CMYK colors are set with setcmykcolor and spot colors become Separation
+// color spaces declared as custom colors. PostScript has no transparency:
+// translucent colors and gradient stops are painted opaque, and logo
+// pixels are either painted or left out.
+type EPSRenderer struct {
+	// ModuleSize is the side of a module in millimetres.
+	ModuleSize float64
+}
+
+// epsPainter collects the page description and the spot colors it uses.
+type epsPainter struct {
+	content bytes.Buffer
+	spots   []SpotColor
+}
+
+// Render writes the EPS file to w.
+func (r EPSRenderer) Render(w io.Writer, s *Scene) error {
+	if r.ModuleSize <= 0 {
+		r.ModuleSize = defaultModuleMM
+	}
+	scale := r.ModuleSize * pointsPerMM
+	side := float64(s.Size()+2*s.QuietZone) * scale
+
+	p := &epsPainter{}
+	// Module units with the y axis pointing down
+	fmt.Fprintf(&p.content, "[%s 0 0 %s 0 %s] concat\n", pdfNumber(scale), pdfNumber(-scale), pdfNumber(side))
+	if err := paintScene(p, s); err != nil {
+		return err
+	}
+
+	var doc bytes.Buffer
+	doc.WriteString("%!PS-Adobe-3.0 EPSF-3.0\n")
+	fmt.Fprintf(&doc, "%%%%BoundingBox: 0 0 %d %d\n", int(math.Ceil(side)), int(math.Ceil(side)))
+	fmt.Fprintf(&doc, "%%%%HiResBoundingBox: 0 0 %s %s\n", pdfNumber(side), pdfNumber(side))
+	doc.WriteString("%%Creator: go-mosaic\n%%LanguageLevel: 3\n")
+	if len(p.spots) > 0 {
+		names := make([]string, len(p.spots))
+		for i, spot := range p.spots {
+			names[i] = psString(spot.Name)
+		}
+		fmt.Fprintf(&doc, "%%%%DocumentCustomColors: %s\n", strings.Join(names, " "))
+		for i, spot := range p.spots {
+			f := spot.Fallback
+			fmt.Fprintf(&doc, "%%%%CMYKCustomColor: %s %s %s %s %s\n", unitValue(f.C), unitValue(f.M), unitValue(f.Y), unitValue(f.K), names[i])
+		}
+	}
+	doc.WriteString("%%EndComments\n%%BeginProlog\n")
+	// Short path operators, the color spaces and the logo row buffer live
+	// in a dictionary of their own, so they do not leak into the including
+	// document
+	fmt.Fprintf(&doc, "/mosaic %d dict def\nmosaic begin\n", 5+len(p.spots))
+	doc.WriteString("/m {moveto} bind def\n/l {lineto} bind def\n/h {closepath} bind def\n")
+	doc.WriteString("/re {4 -2 roll moveto 1 index 0 rlineto 0 exch rlineto neg 0 rlineto closepath} bind def\n")
+	for i, spot := range p.spots {
+		f := spot.Fallback
+		// Tint transform from no ink to the full CMYK fallback
+		fmt.Fprintf(&doc, "/CS%d [/Separation %s cvn /DeviceCMYK {dup %s mul exch dup %s mul exch dup %s mul exch %s mul}] def\n",
+			i, psString(spot.Name), unitValue(f.C), unitValue(f.M), unitValue(f.Y), unitValue(f.K))
+	}
+	doc.WriteString("end\n%%EndProlog\nmosaic begin\ngsave\n")
+	p.content.WriteTo(&doc)
+	doc.WriteString("grestore\nend\nshowpage\n%%Trailer\n%%EOF\n")
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// fill paints the polygons with c, opaque even when c is translucent.
+func (p *epsPainter) fill(c color.Color, polygons ...[][2]float64) {
+	if !Visible(c) {
+		return
+	}
+	p.setColor(c)
+	p.path(polygons)
+	p.content.WriteString("eofill\n")
+}
+
+// shade clips to the polygons and paints an axial or radial shading with
+// the shfill operator of LanguageLevel 3.
+func (p *epsPainter) shade(g *Gradient, geo gradientGeometry, polygons ...[][2]float64) {
+	rgb := func(c color.Color) string {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		return fmt.Sprintf("[%s %s %s]", unitValue(n.R), unitValue(n.G), unitValue(n.B))
+	}
+	p.content.WriteString("gsave\n")
+	p.path(polygons)
+	// PostScript dictionaries share the syntax of PDF ones
+	fmt.Fprintf(&p.content, "eoclip newpath\n%s shfill\ngrestore\n", pdfShading(g, geo, g.stops(), "/DeviceRGB", rgb))
+}
+
+// image draws the RGB samples of img clipped to clip and to its pixels of
+// at least half opacity, as rectangles of pixel runs.
+func (p *epsPainter) image(img image.Image, x, y, size float64, clip [][2]float64) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return
+	}
+	x, y, w, h := fitLogo(bounds, x, y, size)
+	p.content.WriteString("gsave\n")
+	p.path([][][2]float64{clip})
+	p.content.WriteString("clip newpath\n")
+	// Pixel units with the y axis pointing down, row 0 at the top. The
+	// scale of a large logo needs more than 4 decimals.
+	fmt.Fprintf(&p.content, "%s %s translate %s %s scale\n", pdfNumber(x), pdfNumber(y),
+		strconv.FormatFloat(w/float64(bounds.Dx()), 'f', -1, 64), strconv.FormatFloat(h/float64(bounds.Dy()), 'f', -1, 64))
+
+	var samples bytes.Buffer
+	masked := false
+	var runs strings.Builder
+	for py := range bounds.Dy() {
+		start := -1
+		for px := range bounds.Dx() + 1 {
+			opaque := false
+			if px < bounds.Dx() {
+				c := color.NRGBAModel.Convert(img.At(bounds.Min.X+px, bounds.Min.Y+py)).(color.NRGBA)
+				fmt.Fprintf(&samples, "%02x%02x%02x", c.R, c.G, c.B)
+				opaque = c.A >= epsMaskAlpha
+				masked = masked || c.A < 0xff
+			}
+			switch {
+			case opaque && start < 0:
+				start = px
+			case !opaque && start >= 0:
+				fmt.Fprintf(&runs, "%d %d %d 1 re\n", start, py, px-start)
+				start = -1
+			}
+		}
+	}
+	if masked {
+		p.content.WriteString(runs.String())
+		p.content.WriteString("clip newpath\n")
+	}
+	// readhexstring reads exactly one row per call and needs no end of
+	// data marker
+	fmt.Fprintf(&p.content, "/row %d string def\n/DeviceRGB setcolorspace\n", 3*bounds.Dx())
+	fmt.Fprintf(&p.content, "<< /ImageType 1 /Width %d /Height %d /BitsPerComponent 8 /Decode [0 1 0 1 0 1] /ImageMatrix [1 0 0 1 0 0] /DataSource {currentfile row readhexstring pop} >> image\n",
+		bounds.Dx(), bounds.Dy())
+	hex := samples.String()
+	for len(hex) > 72 {
+		p.content.WriteString(hex[:72] + "\n")
+		hex = hex[72:]
+	}
+	p.content.WriteString(hex + "\ngrestore\n")
+}
+
+func (p *epsPainter) setColor(c color.Color) {
+	if spot, ok := ToSpot(c); ok {
+		index := -1
+		for i, known := range p.spots {
+			if known == spot {
+				index = i
+			}
+		}
+		if index < 0 {
+			index = len(p.spots)
+			p.spots = append(p.spots, spot)
+		}
+		fmt.Fprintf(&p.content, "CS%d setcolorspace 1 setcolor\n", index)
+		return
+	}
+	if cmyk, ok := ToCMYK(c); ok {
+		fmt.Fprintf(&p.content, "%s %s %s %s setcmykcolor\n", unitValue(cmyk.C), unitValue(cmyk.M), unitValue(cmyk.Y), unitValue(cmyk.K))
+		return
+	}
+	rgb := color.NRGBAModel.Convert(c).(color.NRGBA)
+	fmt.Fprintf(&p.content, "%s %s %s setrgbcolor\n", unitValue(rgb.R), unitValue(rgb.G), unitValue(rgb.B))
+}
+
+func (p *epsPainter) path(polygons [][][2]float64) {
+	for _, polygon := range polygons {
+		for i, pt := range polygon {
+			op := "l"
+			if i == 0 {
+				op = "m"
+			}
+			fmt.Fprintf(&p.content, "%s %s %s\n", pdfNumber(pt[0]), pdfNumber(pt[1]), op)
+		}
+		p.content.WriteString("h\n")
+	}
+}
+
+// psString returns s as a PostScript string literal.
+func psString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
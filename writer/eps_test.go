@@ -0,0 +1,110 @@
+package writer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestEPSPrintColors(t *testing.T) {
+	s := blankScene(21)
+	s.QuietZone = 0
+	s.Modules[10][10].Dark = true
+	s.Modules[10][12].Dark = true
+	s.Modules[10][12].Role = RoleTiming
+	s.Color = color.CMYK{0, 0, 0, 0xff}
+	s.Colors.Timing = SpotColor{Name: "PANTONE 185 C", Fallback: color.CMYK{0, 0xff, 0xcc, 0}}
+	var buf bytes.Buffer
+	if err := (EPSRenderer{}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	// 21 modules of 1 mm are 59.5276 points
+	for _, want := range []string{
+		"%!PS-Adobe-3.0 EPSF-3.0\n%%BoundingBox: 0 0 60 60\n%%HiResBoundingBox: 0 0 59.5276 59.5276\n",
+		"%%DocumentCustomColors: (PANTONE 185 C)\n%%CMYKCustomColor: 0 1 0.8 0 (PANTONE 185 C)\n",
+		"/CS0 [/Separation (PANTONE 185 C) cvn /DeviceCMYK {dup 0 mul exch dup 1 mul exch dup 0.8 mul exch 0 mul}] def\n",
+		"[2.8346 0 0 -2.8346 0 59.5276] concat\n",
+		"0 0 0 1 setcmykcolor\n10 10 m\n11 10 l\n11 11 l\n10 11 l\nh\neofill\n",
+		"CS0 setcolorspace 1 setcolor\n12 10 m\n",
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("no %q in:\n%s", want, doc)
+		}
+	}
+	if !strings.HasSuffix(doc, "grestore\nend\nshowpage\n%%Trailer\n%%EOF\n") {
+		t.Errorf("document does not end with the page trailer:\n%s", doc)
+	}
+}
+
+func TestEPSTransparency(t *testing.T) {
+	s := blankScene(21)
+	s.QuietZone = 0
+	s.Modules[10][10].Dark = true
+	s.Background = color.NRGBA{}
+	s.Color = color.NRGBA{0, 0, 0xff, 0x80}
+	var buf bytes.Buffer
+	if err := (EPSRenderer{}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	// The transparent background is left out and the translucent modules
+	// are painted opaque
+	doc := buf.String()
+	if n := strings.Count(doc, "eofill"); n != 1 {
+		t.Errorf("%d fills, want the module only:\n%s", n, doc)
+	}
+	if !strings.Contains(doc, "0 0 1 setrgbcolor\n") {
+		t.Errorf("no opaque module color in:\n%s", doc)
+	}
+}
+
+func TestEPSGradient(t *testing.T) {
+	s := blankScene(21)
+	s.QuietZone = 0
+	s.Modules[10][10].Dark = true
+	s.Gradient = &Gradient{Stops: []GradientStop{{0, color.Black}, {1, color.NRGBA{0, 0, 0xff, 0xff}}}}
+	var buf bytes.Buffer
+	if err := (EPSRenderer{}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	want := "h\neoclip newpath\n<< /ShadingType 2 /Coords [0 10.5 21 10.5] /ColorSpace /DeviceRGB " +
+		"/Function << /FunctionType 2 /Domain [0 1] /C0 [0 0 0] /C1 [0 0 1] /N 1 >> /Extend [true true] >> shfill\ngrestore\n"
+	if doc := buf.String(); !strings.Contains(doc, want) {
+		t.Errorf("no %q in:\n%s", want, doc)
+	}
+}
+
+func TestEPSLogo(t *testing.T) {
+	// A logo twice as wide as high with a transparent pixel in its top
+	// right corner
+	logo := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for i := range logo.Pix {
+		logo.Pix[i] = 0xff
+	}
+	logo.SetNRGBA(0, 0, color.NRGBA{0x12, 0x34, 0x56, 0xff})
+	logo.SetNRGBA(3, 0, color.NRGBA{})
+	s := blankScene(21)
+	s.QuietZone = 0
+	s.Logo = &Logo{Image: logo, X: 8, Y: 8, Size: 5, Plate: PlateSquare}
+	var buf bytes.Buffer
+	if err := (EPSRenderer{}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	// Letterboxed into the logo square, clipped to the plate and to the
+	// opaque pixel runs, row by row
+	want := "gsave\n8 8 m\n13 8 l\n13 13 l\n8 13 l\nh\nclip newpath\n" +
+		"8 9.25 translate 1.25 1.25 scale\n" +
+		"0 0 3 1 re\n0 1 4 1 re\nclip newpath\n" +
+		"/row 12 string def\n/DeviceRGB setcolorspace\n" +
+		"<< /ImageType 1 /Width 4 /Height 2 /BitsPerComponent 8 /Decode [0 1 0 1 0 1] /ImageMatrix [1 0 0 1 0 0] /DataSource {currentfile row readhexstring pop} >> image\n" +
+		"123456ffffffffffff000000ffffffffffffffffffffffff\ngrestore\n"
+	doc := buf.String()
+	if !strings.Contains(doc, want) {
+		t.Errorf("no %q in:\n%s", want, doc)
+	}
+	if n := strings.Count(doc, "gsave") - strings.Count(doc, "grestore"); n != 0 {
+		t.Errorf("%d unbalanced gsave operators", n)
+	}
+}
@@ -0,0 +1,204 @@
+package writer
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// EyeShape is the outline of the frame or the ball of the finder and
+// alignment patterns, drawn independently of the module shape. Every shape
+// keeps the frame one module thick around a three module ball, so scan
+// lines through the center still see the 1:1:3:1:1 ratio.
+type EyeShape string
+
+const (
+	EyeSquare  EyeShape = "square"
+	EyeRounded EyeShape = "rounded"
+	EyeCircle  EyeShape = "circle"
+	// EyeLeaf rounds two opposite corners, the pointed ones lie on the
+	// diagonal through the middle of the symbol.
+	EyeLeaf EyeShape = "leaf"
+	// EyeTeardrop rounds every corner but the one facing the middle of the
+	// symbol.
+	EyeTeardrop EyeShape = "teardrop"
+	// EyeDotted draws a dot for every module of the frame or the ball.
+	EyeDotted EyeShape = "dotted"
+)
+
+// corner radius of the rounded eye, as a fraction of its side
+const eyeRounding = 0.3
+
+// eyes returns the shapes of the eye frame and ball, empty for the ones
+// following the module shape. Connected module shapes have no rings of
+// their own, the eyes they do not shape are square. Line shapes always
+// draw them apart, their gaps would break the 1:1:3:1:1 ratio.
+func (s *Scene) eyes() (frame, ball EyeShape) {
+	frame, ball = s.EyeFrame, s.EyeBall
+	if s.Shape.connected() && (frame != "" || ball != "" || s.Shape.lines()) {
+		frame = cmp.Or(frame, EyeSquare)
+		ball = cmp.Or(ball, EyeSquare)
+	}
+	return frame, ball
+}
+
+// customEyes reports whether the eye frame or ball has a shape of its own
+func (s *Scene) customEyes() bool {
+	frame, ball := s.eyes()
+	return frame != "" || ball != ""
+}
+
+// eyeRadii returns the corner radii of shape over a square of side size,
+// clockwise from the top left corner, for a pattern in the top left corner
+// of the symbol
+func eyeRadii(shape EyeShape, size float64) [4]float64 {
+	half := size / 2
+	switch shape {
+	case EyeRounded:
+		r := eyeRounding * size
+		return [4]float64{r, r, r, r}
+	case EyeCircle, EyeDotted:
+		return [4]float64{half, half, half, half}
+	case EyeLeaf:
+		return [4]float64{0, half, 0, half}
+	case EyeTeardrop:
+		return [4]float64{half, half, 0, half}
+	}
+	return [4]float64{}
+}
+
+// mirrorRadii flips radii horizontally and vertically, which turns the top
+// left pattern into the one of another corner
+func mirrorRadii(radii [4]float64, flipX, flipY bool) [4]float64 {
+	if flipX {
+		radii = [4]float64{radii[1], radii[0], radii[3], radii[2]}
+	}
+	if flipY {
+		radii = [4]float64{radii[3], radii[2], radii[1], radii[0]}
+	}
+	return radii
+}
+
+// eyeFlip tells which way the pattern with its top left corner at (x, y)
+// is mirrored, so pointed corners face the middle of the symbol
+func (s *Scene) eyeFlip(x, y, size int) (flipX, flipY bool) {
+	dim := s.Size()
+	return 2*x+size > dim, 2*y+size > dim
+}
+
+// eyeCells returns the top left corners of the modules of a dotted frame
+// or ball of side size, relative to its own top left corner
+func eyeCells(size int, frame bool) [][2]float64 {
+	var cells [][2]float64
+	for y := range size {
+		for x := range size {
+			if !frame || x == 0 || y == 0 || x == size-1 || y == size-1 {
+				cells = append(cells, [2]float64{float64(x), float64(y)})
+			}
+		}
+	}
+	return cells
+}
+
+// eyeOutlines returns the polygons of an eye frame or ball of side size at
+// (x, y), to fill with the even-odd rule. A frame is the outline of the
+// shape with a hole one module in from it.
+func eyeOutlines(shape EyeShape, x, y float64, size int, frame, flipX, flipY bool) [][][2]float64 {
+	if shape == EyeDotted {
+		var dots [][][2]float64
+		for _, cell := range eyeCells(size, frame) {
+			dots = append(dots, scaledOutline(ShapeCircle, 1, x+cell[0], y+cell[1], 0))
+		}
+		return dots
+	}
+	side := float64(size)
+	radii := mirrorRadii(eyeRadii(shape, side), flipX, flipY)
+	outlines := [][][2]float64{cornerOutline(x, y, side, side, radii)}
+	if frame {
+		for i := range radii {
+			radii[i] = math.Max(radii[i]-1, 0)
+		}
+		outlines = append(outlines, cornerOutline(x+1, y+1, side-2, side-2, radii))
+	}
+	return outlines
+}
+
+// cornerOutline returns the polygon of the rectangle at (x, y) with rounded
+// corners of the given radii, clockwise from the top left
+func cornerOutline(x, y, width, height float64, radii [4]float64) [][2]float64 {
+	centers := [4][2]float64{
+		{x + radii[0], y + radii[0]},
+		{x + width - radii[1], y + radii[1]},
+		{x + width - radii[2], y + height - radii[2]},
+		{x + radii[3], y + height - radii[3]},
+	}
+	perCorner := paintSegments / 4
+	var outline [][2]float64
+	// Arcs meeting halfway along a side share their end points, which are
+	// kept once
+	add := func(p [2]float64) {
+		if n := len(outline); n > 0 && math.Abs(outline[n-1][0]-p[0]) < 1e-9 && math.Abs(outline[n-1][1]-p[1]) < 1e-9 {
+			return
+		}
+		outline = append(outline, p)
+	}
+	for c, r := range radii {
+		// The arc of the top left corner starts pointing left
+		start := math.Pi + math.Pi/2*float64(c)
+		if r == 0 {
+			add(centers[c])
+			continue
+		}
+		for i := range perCorner + 1 {
+			a := start + math.Pi/2*float64(i)/float64(perCorner)
+			add([2]float64{centers[c][0] + r*math.Cos(a), centers[c][1] + r*math.Sin(a)})
+		}
+	}
+	if n := len(outline); n > 1 && math.Abs(outline[n-1][0]-outline[0][0]) < 1e-9 && math.Abs(outline[n-1][1]-outline[0][1]) < 1e-9 {
+		outline = outline[:n-1]
+	}
+	return outline
+}
+
+// eyePath returns the SVG path data of an eye frame or ball of side size
+// with its top left corner at the origin, for the top left pattern. It is
+// drawn with the even-odd rule.
+func eyePath(shape EyeShape, size int, frame bool, num func(float64) string) string {
+	var b strings.Builder
+	if shape == EyeDotted {
+		for _, cell := range eyeCells(size, frame) {
+			fmt.Fprintf(&b, "M%s %sa0.5 0.5 0 1 0 1 0a0.5 0.5 0 1 0 -1 0Z", num(cell[0]), num(cell[1]+0.5))
+		}
+		return b.String()
+	}
+	side := float64(size)
+	radii := eyeRadii(shape, side)
+	writeCornerPath(&b, 0, 0, side, side, radii, num)
+	if frame {
+		for i := range radii {
+			radii[i] = math.Max(radii[i]-1, 0)
+		}
+		writeCornerPath(&b, 1, 1, side-2, side-2, radii, num)
+	}
+	return b.String()
+}
+
+// writeCornerPath writes the path of the rectangle at (x, y) with rounded
+// corners of the given radii, clockwise from the top left
+func writeCornerPath(b *strings.Builder, x, y, width, height float64, radii [4]float64, num func(float64) string) {
+	arc := func(r, toX, toY float64) {
+		if r > 0 {
+			fmt.Fprintf(b, "A%s %s 0 0 1 %s %s", num(r), num(r), num(toX), num(toY))
+		}
+	}
+	fmt.Fprintf(b, "M%s %s", num(x), num(y+radii[0]))
+	arc(radii[0], x+radii[0], y)
+	fmt.Fprintf(b, "H%s", num(x+width-radii[1]))
+	arc(radii[1], x+width, y+radii[1])
+	fmt.Fprintf(b, "V%s", num(y+height-radii[2]))
+	arc(radii[2], x+width-radii[2], y+height)
+	fmt.Fprintf(b, "H%s", num(x+radii[3]))
+	arc(radii[3], x, y+height-radii[3])
+	b.WriteString("Z")
+}
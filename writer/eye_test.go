@@ -0,0 +1,69 @@
+package writer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEyeFlip(t *testing.T) {
+	s := blankScene(25)
+	tests := []struct {
+		x, y, size   int
+		flipX, flipY bool
+	}{
+		{0, 0, 7, false, false},
+		{18, 0, 7, true, false},
+		{0, 18, 7, false, true},
+		{16, 16, 5, true, true},
+	}
+	for _, tt := range tests {
+		if flipX, flipY := s.eyeFlip(tt.x, tt.y, tt.size); flipX != tt.flipX || flipY != tt.flipY {
+			t.Errorf("eyeFlip(%d, %d, %d) = %t, %t, want %t, %t", tt.x, tt.y, tt.size, flipX, flipY, tt.flipX, tt.flipY)
+		}
+	}
+
+	// The pointed corner of the teardrop faces the middle of the symbol
+	radii := mirrorRadii(eyeRadii(EyeTeardrop, 7), true, false)
+	if radii[3] != 0 {
+		t.Errorf("top right teardrop radii %v, want the bottom left corner pointed", radii)
+	}
+}
+
+func TestEyeOutlines(t *testing.T) {
+	// The frame keeps a one module ring around the ball
+	frame := eyeOutlines(EyeRounded, 0, 0, 7, true, false, false)
+	if len(frame) != 2 {
+		t.Fatalf("rounded frame has %d outlines, want 2", len(frame))
+	}
+	for _, p := range frame[1] {
+		if p[0] < 1 || p[0] > 6 || p[1] < 1 || p[1] > 6 {
+			t.Errorf("hole of the frame reaches %v", p)
+		}
+	}
+	if dots := eyeOutlines(EyeDotted, 0, 0, 7, true, false, false); len(dots) != 24 {
+		t.Errorf("dotted frame has %d dots, want 24", len(dots))
+	}
+	if dots := eyeOutlines(EyeDotted, 2, 2, 3, false, false, false); len(dots) != 9 {
+		t.Errorf("dotted ball has %d dots, want 9", len(dots))
+	}
+}
+
+func TestEyeSVG(t *testing.T) {
+	s := blankScene(21)
+	s.EyeFrame = EyeLeaf
+	var buf bytes.Buffer
+	if err := (SVGRenderer{IDPrefix: "t-"}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`id="t-eyeframe7" style="fill-rule:evenodd"`,
+		`<use href="#t-eyeframe7"`,
+		`<use href="#t-finderpattern" transform="translate(21.000000 0.000000) scale(-1 1)">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in:\n%s", want, out)
+		}
+	}
+}
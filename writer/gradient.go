@@ -0,0 +1,176 @@
+package writer
+
+import (
+	"encoding/xml"
+	"image/color"
+	"math"
+	"slices"
+)
+
+// GradientKind selects how the colors of a gradient spread.
+type GradientKind string
+
+const (
+	// GradientLinear varies the color along a line at Angle.
+	GradientLinear GradientKind = "linear"
+	// GradientRadial varies the color with the distance to its center.
+	GradientRadial GradientKind = "radial"
+)
+
+// GradientStop is a color at a position along a gradient.
+type GradientStop struct {
+	// Offset goes from 0 at the start of the gradient to 1 at its end.
+	Offset float64
+	Color  color.Color
+}
+
+// Gradient paints the dark modules with colors that vary across the
+// symbol. Before the first stop and past the last one the gradient keeps
+// their colors.
+type Gradient struct {
+	Kind  GradientKind
+	Stops []GradientStop
+	// Angle of a linear gradient in degrees, 0 runs from left to right and
+	// 90 from top to bottom. The first and last stops fall on the opposite
+	// corners of the symbol.
+	Angle float64
+	// CenterX and CenterY place the center of a radial gradient relative
+	// to the middle of the symbol, as fractions of its side.
+	CenterX, CenterY float64
+	// Radius of a radial gradient as a fraction of the symbol side, zero
+	// reaches the farthest corner.
+	Radius float64
+	// Finders paints the finder and alignment patterns with the gradient
+	// too, they keep Scene.Color otherwise.
+	Finders bool
+}
+
+// gradientGeometry is a gradient placed over a symbol: a linear gradient
+// runs from start to end, a radial one from start, its center, to radius.
+type gradientGeometry struct {
+	start, end [2]float64
+	radius     float64
+}
+
+// geometry places g over the symbol square at (x, y) with side size
+func (g *Gradient) geometry(x, y, size float64) gradientGeometry {
+	cx, cy := x+size/2, y+size/2
+	if g.Kind == GradientRadial {
+		cx += g.CenterX * size
+		cy += g.CenterY * size
+		r := g.Radius * size
+		if r <= 0 {
+			for _, corner := range [][2]float64{{x, y}, {x + size, y}, {x, y + size}, {x + size, y + size}} {
+				r = math.Max(r, math.Hypot(corner[0]-cx, corner[1]-cy))
+			}
+		}
+		return gradientGeometry{start: [2]float64{cx, cy}, radius: r}
+	}
+	// Half the length of the symbol projected on the gradient direction,
+	// so the end stops touch the corners as in CSS
+	sin, cos := math.Sincos(g.Angle * math.Pi / 180)
+	half := size / 2 * (math.Abs(cos) + math.Abs(sin))
+	return gradientGeometry{
+		start: [2]float64{cx - cos*half, cy - sin*half},
+		end:   [2]float64{cx + cos*half, cy + sin*half},
+	}
+}
+
+// offset returns the gradient position of the point (x, y), clamped to
+// [0, 1]
+func (g *Gradient) offset(geo gradientGeometry, x, y float64) float64 {
+	var t float64
+	if g.Kind == GradientRadial {
+		if geo.radius > 0 {
+			t = math.Hypot(x-geo.start[0], y-geo.start[1]) / geo.radius
+		}
+	} else {
+		dx, dy := geo.end[0]-geo.start[0], geo.end[1]-geo.start[1]
+		if l := dx*dx + dy*dy; l > 0 {
+			t = ((x-geo.start[0])*dx + (y-geo.start[1])*dy) / l
+		}
+	}
+	return math.Min(math.Max(t, 0), 1)
+}
+
+// stops returns the stops sorted by offset, with the first and last colors
+// extended to offsets 0 and 1
+func (g *Gradient) stops() []GradientStop {
+	stops := slices.Clone(g.Stops)
+	slices.SortStableFunc(stops, func(a, b GradientStop) int {
+		switch {
+		case a.Offset < b.Offset:
+			return -1
+		case a.Offset > b.Offset:
+			return 1
+		}
+		return 0
+	})
+	if len(stops) == 0 {
+		return []GradientStop{{0, color.Black}, {1, color.Black}}
+	}
+	for i := range stops {
+		stops[i].Offset = math.Min(math.Max(stops[i].Offset, 0), 1)
+	}
+	if stops[0].Offset > 0 {
+		stops = append([]GradientStop{{0, stops[0].Color}}, stops...)
+	}
+	if last := stops[len(stops)-1]; last.Offset < 1 {
+		stops = append(stops, GradientStop{1, last.Color})
+	}
+	return stops
+}
+
+// At returns the color at offset t, interpolated in sRGB between the
+// surrounding stops as SVG does.
+func (g *Gradient) At(t float64) color.NRGBA {
+	stops := g.stops()
+	i := 1
+	for i < len(stops)-1 && stops[i].Offset < t {
+		i++
+	}
+	a := color.NRGBAModel.Convert(stops[i-1].Color).(color.NRGBA)
+	b := color.NRGBAModel.Convert(stops[i].Color).(color.NRGBA)
+	f := 0.
+	if span := stops[i].Offset - stops[i-1].Offset; span > 0 {
+		f = math.Min(math.Max((t-stops[i-1].Offset)/span, 0), 1)
+	}
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*f))
+	}
+	return color.NRGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+}
+
+// svgGradient writes g placed by geo as a <linearGradient> or
+// <radialGradient> definition in user space
+func svgGradient(g *Gradient, geo gradientGeometry, id string, num func(float64) string) svgFragment {
+	attr := func(name string, v float64) xml.Attr {
+		return xml.Attr{Name: xml.Name{Local: name}, Value: num(v)}
+	}
+	start := xml.StartElement{
+		Name: xml.Name{Local: "linearGradient"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "id"}, Value: id},
+			{Name: xml.Name{Local: "gradientUnits"}, Value: "userSpaceOnUse"},
+		},
+	}
+	if g.Kind == GradientRadial {
+		start.Name.Local = "radialGradient"
+		start.Attr = append(start.Attr, attr("cx", geo.start[0]), attr("cy", geo.start[1]), attr("r", geo.radius))
+	} else {
+		start.Attr = append(start.Attr, attr("x1", geo.start[0]), attr("y1", geo.start[1]), attr("x2", geo.end[0]), attr("y2", geo.end[1]))
+	}
+	fragment := svgFragment{start}
+	for _, stop := range g.stops() {
+		style := "stop-color:" + rgbFill(stop.Color)
+		if _, _, _, a := stop.Color.RGBA(); a < 0xffff {
+			style += ";stop-opacity:" + unitValue(uint8(a>>8))
+		}
+		element := xml.StartElement{
+			Name: xml.Name{Local: "stop"},
+			Attr: []xml.Attr{attr("offset", stop.Offset), {Name: xml.Name{Local: "style"}, Value: style}},
+		}
+		fragment = append(fragment, element, element.End())
+	}
+	return append(fragment, start.End())
+}
@@ -0,0 +1,73 @@
+package writer
+
+import (
+	"bytes"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGradientAt(t *testing.T) {
+	g := &Gradient{Stops: []GradientStop{
+		{Offset: 0.75, Color: color.RGBA{0, 0, 0xff, 0xff}},
+		{Offset: 0.25, Color: color.RGBA{0xff, 0, 0, 0xff}},
+	}}
+	tests := []struct {
+		t    float64
+		want color.NRGBA
+	}{
+		{0, color.NRGBA{0xff, 0, 0, 0xff}},
+		{0.25, color.NRGBA{0xff, 0, 0, 0xff}},
+		{0.5, color.NRGBA{0x80, 0, 0x80, 0xff}},
+		{1, color.NRGBA{0, 0, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		if got := g.At(tt.t); got != tt.want {
+			t.Errorf("At(%g) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestGradientGeometry(t *testing.T) {
+	// A diagonal gradient runs from corner to corner
+	linear := &Gradient{Kind: GradientLinear, Angle: 45}
+	geo := linear.geometry(0, 0, 10)
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if !near(geo.start[0], 0) || !near(geo.start[1], 0) || !near(geo.end[0], 10) || !near(geo.end[1], 10) {
+		t.Errorf("linear 45° from %v to %v", geo.start, geo.end)
+	}
+	if o := linear.offset(geo, 10, 0); !near(o, 0.5) {
+		t.Errorf("top right corner at %g", o)
+	}
+
+	radial := &Gradient{Kind: GradientRadial, CenterX: -0.5, CenterY: -0.5}
+	geo = radial.geometry(4, 4, 10)
+	if geo.start != [2]float64{4, 4} || !near(geo.radius, math.Hypot(10, 10)) {
+		t.Errorf("radial at %v with radius %g", geo.start, geo.radius)
+	}
+}
+
+func TestGradientSVG(t *testing.T) {
+	s := blankScene(21)
+	s.Modules[10][10].Dark = true
+	s.Shape = ShapeCircle
+	s.Gradient = &Gradient{
+		Kind:  GradientRadial,
+		Stops: []GradientStop{{0, color.Black}, {1, color.RGBA{0, 0, 0x80, 0xff}}},
+	}
+	var buf bytes.Buffer
+	if err := (SVGRenderer{IDPrefix: "t-"}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<radialGradient id="t-gradient" gradientUnits="userSpaceOnUse"`,
+		`<mask id="t-ink">`,
+		`mask="url(#t-ink)" style="fill:url(#t-gradient)"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package writer
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// HTMLLayout selects the markup used by HTMLRenderer.Render.
+type HTMLLayout string
+
+const (
+	// HTMLTable renders a table, which survives every email client including
+	// Outlook.
+	HTMLTable HTMLLayout = "table"
+	// HTMLGrid renders a CSS grid of divs, lighter but unsupported by
+	// Outlook for Windows.
+	HTMLGrid HTMLLayout = "grid"
+)
+
+const defaultHTMLModuleSize = 4
+
+// HTMLRenderer writes symbols as self-contained HTML with inline styles
+// only, for emails where images are blocked. Only the square shape renders
+// in Outlook; circle, rounded and squircle modules use border-radius. The
+// other shapes have no CSS equivalent and are drawn as squares.
+type HTMLRenderer struct {
+	Layout HTMLLayout
+	// ModuleSize is the side of a module in CSS pixels.
+	ModuleSize int
+}
+
+// Render writes the symbol to w. Horizontal runs of modules of the same
+// color are merged into a single cell, except for dark modules of shapes
+// other than square.
+func (r HTMLRenderer) Render(w io.Writer, s *Scene) error {
+	if r.ModuleSize < 1 {
+		r.ModuleSize = defaultHTMLModuleSize
+	}
+
+	bw := bufio.NewWriter(w)
+	shape := htmlShape(s.Shape)
+	switch r.Layout {
+	case HTMLGrid:
+		writeHTMLGrid(bw, s, shape, r.ModuleSize)
+	default:
+		writeHTMLTable(bw, s, shape, r.ModuleSize)
+	}
+	return bw.Flush()
+}
+
+// htmlRun is a horizontal run of modules of the same color
+type htmlRun struct {
+	x, length int
+	dark      bool
+}
+
+// htmlRuns splits a row into runs. Dark modules are only merged for the
+// square shape.
+func htmlRuns(row []Module, shape Shape) []htmlRun {
+	var runs []htmlRun
+	for x, m := range row {
+		dark := m.Dark
+		if n := len(runs); n > 0 && runs[n-1].dark == dark && (!dark || shape == ShapeSquare) {
+			runs[n-1].length++
+			continue
+		}
+		runs = append(runs, htmlRun{x: x, length: 1, dark: dark})
+	}
+	return runs
+}
+
+// htmlShape returns the shape drawn for shape, which is ShapeSquare for
+// the shapes border-radius cannot approximate
+func htmlShape(shape Shape) Shape {
+	switch shape {
+	case ShapeCircle, ShapeRounded, ShapeSquircle:
+		return shape
+	}
+	return ShapeSquare
+}
+
+// borderRadius returns the CSS radius approximating shape
+func borderRadius(shape Shape) string {
+	switch shape {
+	case ShapeCircle:
+		return "border-radius:50%;"
+	case ShapeRounded, ShapeSquircle:
+		return "border-radius:35%;"
+	}
+	return ""
+}
+
+func writeHTMLTable(w *bufio.Writer, s *Scene, shape Shape, size int) {
+	total := s.Size() + 2*s.QuietZone
+	fg, bg := hexColor(s.Color), hexColor(s.Background)
+
+	fmt.Fprintf(w, `<table role="presentation" border="0" cellpadding="0" cellspacing="0" width="%d"%s style="border-collapse:collapse;border-spacing:0;table-layout:fixed;width:%dpx;background-color:%s;">`,
+		total*size, bgcolor(s.Background), total*size, bg)
+	w.WriteString("\n")
+
+	// An initial row of single cells fixes the column widths for clients
+	// that ignore colspan when sizing columns.
+	w.WriteString(`<tr style="font-size:0;line-height:0;">`)
+	for range total {
+		fmt.Fprintf(w, `<td width="%d" style="width:%dpx;"></td>`, size, size)
+	}
+	w.WriteString("</tr>\n")
+
+	// A colspan of 0 counts as 1 in browsers, so an empty quiet zone
+	// writes no cells at all
+	quietRow := func(rows int) {
+		if rows == 0 {
+			return
+		}
+		fmt.Fprintf(w, `<tr><td colspan="%d" height="%d" style="height:%dpx;font-size:0;line-height:0;">&nbsp;</td></tr>`,
+			total, rows*size, rows*size)
+		w.WriteString("\n")
+	}
+	quietCell := func() {
+		if s.QuietZone > 0 {
+			fmt.Fprintf(w, `<td colspan="%d"></td>`, s.QuietZone)
+		}
+	}
+
+	quietRow(s.QuietZone)
+	for _, row := range s.Modules {
+		fmt.Fprintf(w, `<tr height="%d" style="height:%dpx;font-size:0;line-height:0;">`, size, size)
+		quietCell()
+		for _, run := range htmlRuns(row, shape) {
+			if !run.dark {
+				fmt.Fprintf(w, `<td colspan="%d"></td>`, run.length)
+				continue
+			}
+			if shape == ShapeSquare {
+				fmt.Fprintf(w, `<td colspan="%d"%s style="background-color:%s;"></td>`, run.length, bgcolor(s.Color), fg)
+				continue
+			}
+			fmt.Fprintf(w, `<td><div style="width:%dpx;height:%dpx;background-color:%s;%s"></div></td>`,
+				size, size, fg, borderRadius(shape))
+		}
+		quietCell()
+		w.WriteString("</tr>\n")
+	}
+	quietRow(s.QuietZone)
+	w.WriteString("</table>\n")
+}
+
+func writeHTMLGrid(w *bufio.Writer, s *Scene, shape Shape, size int) {
+	fg, bg := hexColor(s.Color), hexColor(s.Background)
+
+	fmt.Fprintf(w, `<div style="display:inline-grid;grid-template-columns:repeat(%d,%dpx);grid-auto-rows:%dpx;padding:%dpx;background-color:%s;line-height:0;">`,
+		s.Size(), size, size, s.QuietZone*size, bg)
+	w.WriteString("\n")
+	for y, row := range s.Modules {
+		for _, run := range htmlRuns(row, shape) {
+			if !run.dark {
+				continue
+			}
+			fmt.Fprintf(w, `<div style="grid-row:%d;grid-column:%d / span %d;background-color:%s;%s"></div>`,
+				y+1, run.x+1, run.length, fg, borderRadius(shape))
+		}
+		w.WriteString("\n")
+	}
+	w.WriteString("</div>\n")
+}
+
+// hexColor formats opaque colors as #rrggbb, the most widely supported
+// notation in email clients, and the others as transparent or rgba()
+func hexColor(c color.Color) string {
+	if !Visible(c) {
+		return "transparent"
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A < 0xff {
+		return fmt.Sprintf("rgba(%d,%d,%d,%s)", n.R, n.G, n.B, unitValue(n.A))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+}
+
+// bgcolor returns the bgcolor attribute of an opaque color. The attribute
+// only takes plain colors, translucent and transparent ones rely on the
+// background-color style alone.
+func bgcolor(c color.Color) string {
+	if !Visible(c) || color.NRGBAModel.Convert(c).(color.NRGBA).A < 0xff {
+		return ""
+	}
+	return fmt.Sprintf(` bgcolor="%s"`, hexColor(c))
+}
@@ -0,0 +1,148 @@
+package writer
+
+import (
+	"bytes"
+	"image/color"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// tableColumns returns the number of columns spanned by each row of a
+// table written by HTMLRenderer, after the row fixing the column widths
+func tableColumns(t *testing.T, out string) []int {
+	t.Helper()
+	colspan := regexp.MustCompile(`<td(?: colspan="(\d+)")?`)
+	var columns []int
+	for _, row := range strings.Split(out, "<tr")[2:] {
+		n := 0
+		for _, m := range colspan.FindAllStringSubmatch(row, -1) {
+			span := 1
+			if m[1] != "" {
+				span, _ = strconv.Atoi(m[1])
+			}
+			if span == 0 {
+				t.Errorf("colspan 0 in row %s", row)
+			}
+			n += span
+		}
+		columns = append(columns, n)
+	}
+	return columns
+}
+
+func TestHTMLTableQuietZone(t *testing.T) {
+	for _, qz := range []int{0, 2} {
+		s := sceneFromRows("#.#", ".#.", "##.")
+		s.QuietZone = qz
+		var buf bytes.Buffer
+		if err := (HTMLRenderer{}).Render(&buf, s); err != nil {
+			t.Fatal(err)
+		}
+		columns := tableColumns(t, buf.String())
+		if rows := 3 + 2*min(qz, 1); len(columns) != rows {
+			t.Errorf("quiet zone %d: %d rows, want %d", qz, len(columns), rows)
+		}
+		for i, n := range columns {
+			if n != 3+2*qz {
+				t.Errorf("quiet zone %d: row %d spans %d columns, want %d", qz, i, n, 3+2*qz)
+			}
+		}
+	}
+}
+
+func TestHTMLRuns(t *testing.T) {
+	row := sceneFromRows("##.#", "....", "....", "....").Modules[0]
+	tests := []struct {
+		shape Shape
+		want  []htmlRun
+	}{
+		{ShapeSquare, []htmlRun{{0, 2, true}, {2, 1, false}, {3, 1, true}}},
+		{ShapeCircle, []htmlRun{{0, 1, true}, {1, 1, true}, {2, 1, false}, {3, 1, true}}},
+	}
+	for _, tt := range tests {
+		if got := htmlRuns(row, tt.shape); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.shape, got, tt.want)
+		}
+	}
+
+	light := sceneFromRows("....", "....", "....", "....").Modules[0]
+	if got, want := htmlRuns(light, ShapeCircle), []htmlRun{{0, 4, false}}; !slices.Equal(got, want) {
+		t.Errorf("light row: %v, want %v", got, want)
+	}
+}
+
+func TestHTMLLayouts(t *testing.T) {
+	const (
+		light  = `<td colspan="1"></td>`
+		square = `<td colspan="2" bgcolor="#000000" style="background-color:#000000;"></td>`
+		circle = `<td><div style="width:4px;height:4px;background-color:#000000;border-radius:50%;"></div></td>`
+	)
+	tests := []struct {
+		name   string
+		layout HTMLLayout
+		shape  Shape
+		want   []string
+	}{
+		{"table", HTMLTable, ShapeSquare, []string{
+			`<table role="presentation" border="0" cellpadding="0" cellspacing="0" width="8" bgcolor="#ffffff" style="border-collapse:collapse;border-spacing:0;table-layout:fixed;width:8px;background-color:#ffffff;">`,
+			`<tr height="4" style="height:4px;font-size:0;line-height:0;">` + square + `</tr>`,
+			`<tr height="4" style="height:4px;font-size:0;line-height:0;">` + light + `<td colspan="1" bgcolor="#000000" style="background-color:#000000;"></td></tr>`,
+		}},
+		{"table circles", HTMLTable, ShapeCircle, []string{
+			`<tr height="4" style="height:4px;font-size:0;line-height:0;">` + circle + circle + `</tr>`,
+		}},
+		{"table liquid", HTMLTable, ShapeLiquid, []string{
+			`<tr height="4" style="height:4px;font-size:0;line-height:0;">` + square + `</tr>`,
+		}},
+		{"grid", HTMLGrid, ShapeSquare, []string{
+			`<div style="display:inline-grid;grid-template-columns:repeat(2,4px);grid-auto-rows:4px;padding:0px;background-color:#ffffff;line-height:0;">`,
+			`<div style="grid-row:1;grid-column:1 / span 2;background-color:#000000;"></div>`,
+			`<div style="grid-row:2;grid-column:2 / span 1;background-color:#000000;"></div>`,
+		}},
+		{"grid rounded", HTMLGrid, ShapeRounded, []string{
+			`<div style="grid-row:1;grid-column:1 / span 1;background-color:#000000;border-radius:35%;"></div>` +
+				`<div style="grid-row:1;grid-column:2 / span 1;background-color:#000000;border-radius:35%;"></div>`,
+		}},
+		{"grid connected", HTMLGrid, ShapeConnected, []string{
+			`<div style="grid-row:1;grid-column:1 / span 2;background-color:#000000;"></div>`,
+		}},
+	}
+	for _, tt := range tests {
+		s := sceneFromRows("##", ".#")
+		s.QuietZone = 0
+		s.Shape = tt.shape
+		var buf bytes.Buffer
+		if err := (HTMLRenderer{Layout: tt.layout}).Render(&buf, s); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: no %s in\n%s", tt.name, want, out)
+			}
+		}
+	}
+}
+
+func TestHTMLTableBgcolor(t *testing.T) {
+	s := sceneFromRows("##", ".#")
+	s.QuietZone = 0
+	s.Background = color.Transparent
+	s.Color = color.NRGBA{0, 0, 0, 0x80}
+	var buf bytes.Buffer
+	if err := (HTMLRenderer{}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "bgcolor") {
+		t.Errorf("bgcolor with a transparent background and a translucent color:\n%s", out)
+	}
+	for _, want := range []string{"background-color:transparent;", "background-color:rgba(0,0,0,0.502);"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("no %s in\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	svg "github.com/twpayne/go-svg"
+
+	// logo formats
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+const (
+	svgNamespace   = "http://www.w3.org/2000/svg"
+	xlinkNamespace = "http://www.w3.org/1999/xlink"
+	xmlNamespace   = "http://www.w3.org/XML/1998/namespace"
+)
+
+// IsSVGLogo reports whether the logo at href is an SVG document, which is
+// drawn as vector content instead of an image.
+func IsSVGLogo(href string) bool {
+	return strings.EqualFold(filepath.Ext(href), ".svg")
+}
+
+// logoImage returns img when the logo was prepared in memory, and decodes
+// the raster logo file at href otherwise
+func logoImage(href string, img image.Image) (image.Image, error) {
+	if img != nil {
+		return img, nil
+	}
+	if IsSVGLogo(href) {
+		return nil, fmt.Errorf("%s: svg logos can only be drawn by the svg renderer", href)
+	}
+	file, err := os.Open(href)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err = image.Decode(file)
+	return img, err
+}
+
+// fitLogo returns the rectangle an image with bounds takes in the square of
+// side size at (x, y): scaled to fit and centered, as the default
+// preserveAspectRatio of SVG draws it
+func fitLogo(bounds image.Rectangle, x, y, size float64) (fx, fy, w, h float64) {
+	if bounds.Empty() {
+		return x, y, size, size
+	}
+	scale := size / float64(max(bounds.Dx(), bounds.Dy()))
+	w, h = float64(bounds.Dx())*scale, float64(bounds.Dy())*scale
+	return x + (size-w)/2, y + (size-h)/2, w, h
+}
+
+// logoDataURI returns the raster logo file at href as a base64 data URI.
+func logoDataURI(href string) (string, error) {
+	data, err := os.ReadFile(href)
+	if err != nil {
+		return "", err
+	}
+	mediaType := http.DetectContentType(data)
+	if !strings.HasPrefix(mediaType, "image/") {
+		return "", fmt.Errorf("%s: unsupported logo type %s", href, mediaType)
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// imageDataURI returns img encoded as a PNG data URI.
+func imageDataURI(img image.Image) (string, error) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(b.Bytes()), nil
+}
+
+// svgFragment is an SVG document nested in another one. It is replayed
+// token by token when the outer document is encoded.
+type svgFragment []xml.Token
+
+// MarshalXML implements encoding/xml.Marshaler.
+func (f svgFragment) MarshalXML(encoder *xml.Encoder, _ xml.StartElement) error {
+	for _, token := range f {
+		if err := encoder.EncodeToken(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inlineSVGLogo reads the SVG document at href and places its root element
+// over the square of side size at (x, y). The document keeps its viewBox,
+// which scales the drawing into the logo area. Elements and attributes of
+// editor namespaces are dropped, and prefix is prepended to the ids of the
+// document so two logos inlined in one page do not collide.
+func inlineSVGLogo(href string, x, y, size float64, prefix string) (svgFragment, error) {
+	data, err := os.ReadFile(href)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var fragment svgFragment
+	// names of the open elements, "" for dropped ones
+	var open []string
+	skipping := func() bool { return len(open) > 0 && open[len(open)-1] == "" }
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", href, err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skipping() || (t.Name.Space != svgNamespace && t.Name.Space != "") {
+				open = append(open, "")
+				continue
+			}
+			start := xml.StartElement{Name: xml.Name{Local: t.Name.Local}}
+			for _, attr := range t.Attr {
+				if name, ok := svgAttrName(attr.Name); ok {
+					start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: name}, Value: attr.Value})
+				}
+			}
+			if len(open) == 0 {
+				if t.Name.Local != "svg" {
+					return nil, fmt.Errorf("%s: root element is %s, not svg", href, t.Name.Local)
+				}
+				start.Attr = fitSVGLogo(start.Attr, x, y, size)
+			}
+			open = append(open, t.Name.Local)
+			fragment = append(fragment, start)
+		case xml.EndElement:
+			if len(open) == 0 {
+				continue
+			}
+			name := open[len(open)-1]
+			open = open[:len(open)-1]
+			if name != "" {
+				fragment = append(fragment, xml.EndElement{Name: xml.Name{Local: name}})
+			}
+		case xml.CharData:
+			if len(open) > 0 && !skipping() && len(bytes.TrimSpace(t)) > 0 {
+				fragment = append(fragment, t.Copy())
+			}
+		}
+	}
+	if len(fragment) == 0 {
+		return nil, fmt.Errorf("%s: empty svg document", href)
+	}
+	prefixSVGIDs(fragment, prefix)
+	return fragment, nil
+}
+
+// svgReference matches the references to an id in attribute values and
+// style sheets: href="#id", url(#id) and #id selectors
+var svgReference = regexp.MustCompile(`#([A-Za-z_][\w.:-]*)`)
+
+// prefixSVGIDs prepends prefix to the ids defined in fragment and to the
+// references to them. References to ids the fragment does not define are
+// left alone.
+func prefixSVGIDs(fragment svgFragment, prefix string) {
+	if prefix == "" {
+		return
+	}
+	ids := map[string]bool{}
+	for _, token := range fragment {
+		if start, ok := token.(xml.StartElement); ok {
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "id" {
+					ids[attr.Value] = true
+				}
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	rewrite := func(s string) string {
+		return svgReference.ReplaceAllStringFunc(s, func(ref string) string {
+			if ids[ref[1:]] {
+				return "#" + prefix + ref[1:]
+			}
+			return ref
+		})
+	}
+	inStyle := false
+	for i, token := range fragment {
+		switch t := token.(type) {
+		case xml.StartElement:
+			inStyle = t.Name.Local == "style"
+			attrs := slices.Clone(t.Attr)
+			for j, attr := range attrs {
+				switch {
+				case attr.Name.Local == "id":
+					attrs[j].Value = prefix + attr.Value
+				case attr.Name.Local == "href", attr.Name.Local == "style", strings.Contains(attr.Value, "url("):
+					attrs[j].Value = rewrite(attr.Value)
+				}
+			}
+			t.Attr = attrs
+			fragment[i] = t
+		case xml.EndElement:
+			inStyle = false
+		case xml.CharData:
+			if inStyle {
+				fragment[i] = xml.CharData(rewrite(string(t)))
+			}
+		}
+	}
+}
+
+// svgAttrName returns the prefixed name of an attribute of the svg, xlink
+// or xml namespaces. Default namespace declarations are dropped since the
+// nested document inherits the svg namespace.
+func svgAttrName(name xml.Name) (string, bool) {
+	switch name.Space {
+	case "":
+		return name.Local, name.Local != "xmlns"
+	case xlinkNamespace:
+		return "xlink:" + name.Local, true
+	case xmlNamespace:
+		return "xml:" + name.Local, true
+	case "xmlns":
+		return "xmlns:xlink", name.Local == "xlink"
+	}
+	return "", false
+}
+
+// fitSVGLogo replaces the position and size attributes of the logo root
+// element, adding a viewBox from its original size when it has none
+func fitSVGLogo(attrs []xml.Attr, x, y, size float64) []xml.Attr {
+	var width, height string
+	hasViewBox := false
+	kept := attrs[:0]
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "x", "y":
+		case "width":
+			width = attr.Value
+		case "height":
+			height = attr.Value
+		case "viewBox":
+			hasViewBox = true
+			kept = append(kept, attr)
+		default:
+			kept = append(kept, attr)
+		}
+	}
+	if !hasViewBox {
+		w, errW := strconv.ParseFloat(strings.TrimSuffix(width, "px"), 64)
+		h, errH := strconv.ParseFloat(strings.TrimSuffix(height, "px"), 64)
+		if errW == nil && errH == nil {
+			kept = append(kept, xml.Attr{Name: xml.Name{Local: "viewBox"}, Value: fmt.Sprintf("0 0 %g %g", w, h)})
+		}
+	}
+	return append(kept,
+		xml.Attr{Name: xml.Name{Local: "x"}, Value: strconv.FormatFloat(x, 'f', -1, 64)},
+		xml.Attr{Name: xml.Name{Local: "y"}, Value: strconv.FormatFloat(y, 'f', -1, 64)},
+		xml.Attr{Name: xml.Name{Local: "width"}, Value: strconv.FormatFloat(size, 'f', -1, 64)},
+		xml.Attr{Name: xml.Name{Local: "height"}, Value: strconv.FormatFloat(size, 'f', -1, 64)},
+	)
+}
+
+// logoElement returns the SVG element drawing the logo over its area,
+// clipped to clip. Raster logos are embedded as data URIs unless link is
+// set, SVG logos are always inlined with their ids prefixed by prefix.
+// Images prepared in memory are always embedded.
+func logoElement(logo *Logo, clip, prefix string, link bool) (svg.Element, error) {
+	x, y, size := float64(logo.X), float64(logo.Y), float64(logo.Size)
+	if logo.Image == nil && IsSVGLogo(logo.Href) {
+		fragment, err := inlineSVGLogo(logo.Href, x, y, size, prefix)
+		if err != nil {
+			return nil, err
+		}
+		return svg.G(fragment).ClipPath(svg.String(clip)), nil
+	}
+	href := logo.Href
+	var err error
+	switch {
+	case logo.Image != nil:
+		href, err = imageDataURI(logo.Image)
+	case !link:
+		href, err = logoDataURI(logo.Href)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return svg.Image().Href(svg.String(href)).XYWidthHeight(x, y, size, size, svg.Number).ClipPath(svg.String(clip)), nil
+}
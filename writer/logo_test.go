@@ -0,0 +1,180 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInlineSVGLogo(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []string
+		dropped  []string
+	}{
+		{
+			name:     "viewBox is kept",
+			document: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10" width="200"><circle r="5"/></svg>`,
+			want:     []string{`viewBox="0 0 10 10"`, `x="3" y="3" width="5" height="5"`, `<circle r="5">`},
+			dropped:  []string{`width="200"`},
+		},
+		{
+			name:     "viewBox from size",
+			document: `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="40px" height="20"></svg>`,
+			want:     []string{`viewBox="0 0 40 20"`},
+		},
+		{
+			name: "editor namespaces are dropped",
+			document: `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"
+				xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" inkscape:version="1">
+				<inkscape:namedview><inkscape:grid/></inkscape:namedview><use xlink:href="#a"/></svg>`,
+			want:    []string{`xmlns:xlink="http://www.w3.org/1999/xlink"`, `<use xlink:href="#a">`},
+			dropped: []string{"inkscape", "namedview"},
+		},
+		{
+			name: "ids are prefixed",
+			document: `<svg xmlns="http://www.w3.org/2000/svg"><defs><linearGradient id="g"/></defs>
+				<style>#g{opacity:1}</style><rect fill="url(#g)" style="stroke:url(#g)"/><use href="#g"/><use href="#other"/></svg>`,
+			want: []string{`id="p-g"`, `#p-g{opacity:1}`, `fill="url(#p-g)"`, `style="stroke:url(#p-g)"`, `href="#p-g"`, `href="#other"`},
+		},
+	}
+
+	dir := t.TempDir()
+	for _, test := range tests {
+		path := filepath.Join(dir, "logo.svg")
+		if err := os.WriteFile(path, []byte(test.document), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		fragment, err := inlineSVGLogo(path, 3, 3, 5, "p-")
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var buf bytes.Buffer
+		encoder := xml.NewEncoder(&buf)
+		if err := fragment.MarshalXML(encoder, xml.StartElement{}); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		encoder.Flush()
+		got := buf.String()
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: %s does not contain %s", test.name, got, want)
+			}
+		}
+		for _, dropped := range test.dropped {
+			if strings.Contains(got, dropped) {
+				t.Errorf("%s: %s still contains %s", test.name, got, dropped)
+			}
+		}
+	}
+}
+
+// blankScene returns a light scene of dim data modules
+func blankScene(dim int) *Scene {
+	rows := make([]string, dim)
+	for i := range rows {
+		rows[i] = strings.Repeat(".", dim)
+	}
+	return sceneFromRows(rows...)
+}
+
+func TestShapeKnockout(t *testing.T) {
+	// A logo opaque only in its top left quarter
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for y := range 20 {
+		for x := range 20 {
+			img.Set(x, y, color.NRGBA{A: 0xff})
+		}
+	}
+	href := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(file, img)
+	file.Close()
+
+	s := blankScene(21)
+	for _, tc := range []struct {
+		margin  float64
+		cleared int
+	}{
+		// 3.5 opaque modules overlap 4 modules per side
+		{-1, 16},
+		// the samples sit 1/16 past the top and left edges and 9/16 before
+		// the right and bottom ones
+		{0, 25},
+		{1.5, 48},
+	} {
+		if err := s.PlaceLogo(LogoOptions{Href: href, RelativeSize: 7. / 21., Plate: PlateShape, Margin: tc.margin}); err != nil {
+			t.Fatal(err)
+		}
+		cleared, covered := 0, 0
+		for y := range s.Size() {
+			for x := range s.Size() {
+				if s.Logo.Cleared[y][x] {
+					cleared++
+				}
+				if s.Logo.Covered[y][x] {
+					covered++
+				}
+			}
+		}
+		if cleared != tc.cleared || covered != 16 {
+			t.Errorf("margin %g: %d cleared, %d covered; want %d, 16", tc.margin, cleared, covered, tc.cleared)
+		}
+	}
+}
+
+func TestShapeKnockoutFit(t *testing.T) {
+	// An opaque logo twice as wide as high, letterboxed into its square
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	s := blankScene(21)
+	if err := s.PlaceLogo(LogoOptions{Image: img, RelativeSize: 7. / 21., Plate: PlateShape, Margin: -1}); err != nil {
+		t.Fatal(err)
+	}
+	// The logo spans rows 1.75 to 5.25 of its 7 module square
+	for y := range 7 {
+		for x := range 7 {
+			want := y >= 1 && y <= 5
+			if got := s.Logo.Covered[s.Logo.Y+y][s.Logo.X+x]; got != want {
+				t.Errorf("module (%d, %d) of the logo covered %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestLogoPlacement(t *testing.T) {
+	s := blankScene(25)
+	// Function modules across the center
+	for x := range s.Size() {
+		s.Modules[12][x].Role = RoleTiming
+	}
+	opts := LogoOptions{Href: "logo.png", RelativeSize: 5. / 25., Plate: PlateSquare}
+
+	if err := s.PlaceLogo(opts); err != nil || s.Logo.X != 10 || s.Logo.Y != 10 {
+		t.Errorf("centered logo at %d,%d: %v", s.Logo.X, s.Logo.Y, err)
+	}
+	opts.Offset = image.Pt(0, 2)
+	if err := s.PlaceLogo(opts); err == nil {
+		t.Error("logo over the timing row accepted")
+	}
+	opts.Placement = PlaceAuto
+	if err := s.PlaceLogo(opts); err != nil {
+		t.Fatal(err)
+	}
+	// The square plate clears a ring of one module around the logo
+	if s.Logo.X != 10 || s.Logo.Y != 14 {
+		t.Errorf("auto placement at %d,%d, want 10,14", s.Logo.X, s.Logo.Y)
+	}
+}
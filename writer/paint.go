@@ -0,0 +1,171 @@
+package writer
+
+import (
+	"image"
+	"image/color"
+)
+
+// polygon vertices used for curved shapes by the vector and raster painters
+const paintSegments = 32
+
+// painter fills polygons given in module units, with the origin at the top
+// left corner of the quiet zone and the y axis pointing down. Polygons are
+// filled together with the even-odd rule, so a polygon inside another one
+// makes a hole.
+type painter interface {
+	fill(c color.Color, polygons ...[][2]float64)
+	// shade fills polygons with g placed by geo.
+	shade(g *Gradient, geo gradientGeometry, polygons ...[][2]float64)
+	// image draws img fitted into the square at (x, y) as fitLogo places
+	// it, clipped to clip.
+	image(img image.Image, x, y, size float64, clip [][2]float64)
+}
+
+// paintScene draws s with the same layout as the SVG renderer: individual
+// modules, finder and alignment overlays, the logo clearance and the logo.
+func paintScene(p painter, s *Scene) error {
+	total := float64(s.Size() + 2*s.QuietZone)
+	qz := float64(s.QuietZone)
+	margin := s.QuietZoneColor
+	if margin == nil {
+		margin = s.Background
+	}
+	symbol := scaledOutline(ShapeSquare, float64(s.Size()), qz, qz, 0)
+	if margin != s.Background {
+		// A frame, which keeps a translucent background off the margin
+		if Visible(margin) {
+			p.fill(margin, scaledOutline(ShapeSquare, total, 0, 0, 0), symbol)
+		}
+		if Visible(s.Background) {
+			p.fill(s.Background, symbol)
+		}
+	} else if Visible(margin) {
+		p.fill(margin, scaledOutline(ShapeSquare, total, 0, 0, 0))
+	}
+
+	// ink fills polygons with c, or with the gradient when c is nil
+	ink := func(c color.Color, polygons ...[][2]float64) {
+		if len(polygons) == 0 {
+			return
+		}
+		if c == nil {
+			p.shade(s.Gradient, s.Gradient.geometry(qz, qz, float64(s.Size())), polygons...)
+			return
+		}
+		p.fill(c, polygons...)
+	}
+
+	// Square and connected modules draw the finder and alignment patterns
+	// with the rest, unless the eyes have shapes of their own
+	overlays := (s.Shape != ShapeSquare && !s.Shape.connected()) || s.customEyes()
+	switch {
+	case s.Shape == ShapeSquare:
+		// Squares merge into outlines, which avoids seams between modules,
+		// one set of outlines per color
+		for _, fill := range s.moduleFills(!overlays) {
+			var polygons [][][2]float64
+			contours := traceContours(s.Size(), func(x, y int) bool {
+				return s.Dark(x, y) && !s.Cleared(x, y) && (!overlays || !s.IsOverlay(x, y)) && s.moduleFill(x, y) == fill
+			})
+			for _, contour := range contours {
+				polygon := make([][2]float64, len(contour))
+				for i, pt := range contour {
+					polygon[i] = [2]float64{float64(pt.X) + qz, float64(pt.Y) + qz}
+				}
+				polygons = append(polygons, polygon)
+			}
+			ink(fill, polygons...)
+		}
+	case s.Shape.connected():
+		// The pieces of each color are filled together, so neighbors merge
+		// without seams
+		for _, fill := range s.moduleFills(!overlays) {
+			var polygons [][][2]float64
+			for _, part := range s.connectedParts(fill, overlays) {
+				polygons = append(polygons, part.outline(qz, qz))
+			}
+			ink(fill, polygons...)
+		}
+	default:
+		for _, fill := range s.moduleFills(false) {
+			var polygons [][][2]float64
+			for y, row := range s.Modules {
+				for x, m := range row {
+					if m.Dark && !s.IsOverlay(x, y) && !s.Cleared(x, y) && s.moduleFill(x, y) == fill {
+						polygons = append(polygons, scaledOutline(s.Shape, 1, float64(x)+qz, float64(y)+qz, 0))
+					}
+				}
+			}
+			ink(fill, polygons...)
+		}
+	}
+	if overlays {
+		// Rings of 7, 5 and 3 modules for finders, 5, 3 and 1 for alignment
+		// patterns
+		eyeFrame, eyeBall := s.eyes()
+		rings := func(col, row, size int, outer, center color.Color) {
+			x, y := float64(col)+qz, float64(row)+qz
+			side := float64(size)
+			flipX, flipY := s.eyeFlip(col, row, size)
+			if eyeFrame != "" {
+				ink(outer, eyeOutlines(eyeFrame, x, y, size, true, flipX, flipY)...)
+			} else {
+				ink(outer,
+					scaledOutline(s.Shape, side, x, y, 0.2),
+					scaledOutline(s.Shape, side-2, x+1, y+1, 0),
+				)
+			}
+			if eyeBall != "" {
+				ink(center, eyeOutlines(eyeBall, x+2, y+2, size-4, false, flipX, flipY)...)
+			} else {
+				ink(center, scaledOutline(s.Shape, side-4, x+2, y+2, 0))
+			}
+		}
+		for _, ap := range s.AlignmentPatterns {
+			rings(ap[1]-2, ap[0]-2, 5, s.fill(ComponentAlignment), s.fill(ComponentAlignment))
+		}
+		for _, f := range s.Finders {
+			rings(f[0], f[1], 7, s.fill(ComponentFinderOuter), s.fill(ComponentFinderCenter))
+		}
+	}
+
+	logo := s.Logo
+	if logo == nil {
+		return nil
+	}
+	img, err := logoImage(logo.Href, logo.Image)
+	if err != nil {
+		return err
+	}
+	x, y := float64(logo.X)+qz, float64(logo.Y)+qz
+	size := float64(logo.Size)
+	// The plate and its border are half a module larger than the logo
+	plate := logo.Plate.shape()
+	if logo.PlateColor != nil && logo.Plate != PlateNone && logo.Plate != PlateShape {
+		p.fill(logo.PlateColor, scaledOutline(plate, size+1, x-0.5, y-0.5, 0))
+	}
+	if width := logo.BorderWidth; width > 0 {
+		p.fill(s.Color,
+			scaledOutline(plate, size+1+width, x-0.5-width/2, y-0.5-width/2, 0),
+			scaledOutline(plate, size+1-width, x-0.5+width/2, y-0.5+width/2, 0),
+		)
+	}
+	p.image(img, x, y, size, scaledOutline(plate, size, x, y, 0))
+	return nil
+}
+
+// scaledOutline returns the outline of shape covering the square of side
+// size at (x, y), shrunk by padding as GetTransform does
+func scaledOutline(shape Shape, size, x, y, padding float64) [][2]float64 {
+	if shape == ShapeSquare {
+		padding = 0
+	}
+	side := size - padding
+	x += padding / 2
+	y += padding / 2
+	outline := ShapeOutline(shape, paintSegments)
+	for i, pt := range outline {
+		outline[i] = [2]float64{x + pt[0]*side, y + pt[1]*side}
+	}
+	return outline
+}
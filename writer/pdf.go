@@ -0,0 +1,348 @@
+package writer
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const pointsPerMM = 72 / 25.4
+
+// PDFRenderer writes a single page PDF document the size of the symbol
+// with its quiet zone. CMYK colors are kept as DeviceCMYK and spot colors
+// become Separation color spaces, so the file is usable for print.
+// Translucent colors and gradient stops use the PDF 1.4 transparency
+// model.
+type PDFRenderer struct {
+	// ModuleSize is the side of a module in millimetres.
+	ModuleSize float64
+}
+
+// pdfPainter collects the page content stream and its resources.
+type pdfPainter struct {
+	content bytes.Buffer
+	// spot colors in order of use, one Separation color space each
+	spots  []SpotColor
+	images []image.Image
+	// shading dictionaries of the gradients in order of use
+	shadings []string
+	// graphics states of translucent fills and gradients
+	states []pdfState
+}
+
+// pdfState is an ExtGState setting a constant alpha, or a soft mask when
+// mask holds the form drawing the alpha of a gradient.
+type pdfState struct {
+	alpha uint8
+	mask  []byte
+}
+
+// Render writes the PDF document to w.
+func (r PDFRenderer) Render(w io.Writer, s *Scene) error {
+	if r.ModuleSize <= 0 {
+		r.ModuleSize = defaultModuleMM
+	}
+	scale := r.ModuleSize * pointsPerMM
+	side := float64(s.Size()+2*s.QuietZone) * scale
+
+	p := &pdfPainter{}
+	// Module units with the y axis pointing down
+	fmt.Fprintf(&p.content, "%s 0 0 %s 0 %s cm\n", pdfNumber(scale), pdfNumber(-scale), pdfNumber(side))
+	if err := paintScene(p, s); err != nil {
+		return err
+	}
+
+	// Objects 1 to 4 are the catalog, the page tree, the page and its
+	// content, followed by the color spaces, the shadings, the graphics
+	// states and the images.
+	var objects [][]byte
+	var colorSpaces, shadings, xObjects []string
+	next := 5
+	for i := range p.spots {
+		colorSpaces = append(colorSpaces, fmt.Sprintf("/CS%d %d 0 R", i, next))
+		next++
+	}
+	for i := range p.shadings {
+		shadings = append(shadings, fmt.Sprintf("/Sh%d %d 0 R", i, next))
+		next++
+	}
+	var states []string
+	var stateObjects [][]byte
+	for i, state := range p.states {
+		states = append(states, fmt.Sprintf("/GS%d %d 0 R", i, next))
+		if state.mask == nil {
+			stateObjects = append(stateObjects, []byte(fmt.Sprintf("<< /Type /ExtGState /ca %s /CA %s >>", unitValue(state.alpha), unitValue(state.alpha))))
+			next++
+			continue
+		}
+		stateObjects = append(stateObjects,
+			[]byte(fmt.Sprintf("<< /Type /ExtGState /SMask << /Type /Mask /S /Luminosity /G %d 0 R >> >>", next+1)),
+			state.mask,
+		)
+		next += 2
+	}
+	var imageObjects [][]byte
+	for i, img := range p.images {
+		rgb, alpha := pdfImageData(img)
+		bounds := img.Bounds()
+		dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8", bounds.Dx(), bounds.Dy())
+		xObjects = append(xObjects, fmt.Sprintf("/Im%d %d 0 R", i, next))
+		if alpha != nil {
+			dict += fmt.Sprintf(" /SMask %d 0 R", next+1)
+		}
+		imageObjects = append(imageObjects, pdfStream(dict, rgb))
+		next++
+		if alpha != nil {
+			imageObjects = append(imageObjects, pdfStream(
+				fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8", bounds.Dx(), bounds.Dy()),
+				alpha,
+			))
+			next++
+		}
+	}
+
+	resources := ""
+	if len(colorSpaces) > 0 {
+		resources += " /ColorSpace << " + strings.Join(colorSpaces, " ") + " >>"
+	}
+	if len(shadings) > 0 {
+		resources += " /Shading << " + strings.Join(shadings, " ") + " >>"
+	}
+	if len(states) > 0 {
+		resources += " /ExtGState << " + strings.Join(states, " ") + " >>"
+	}
+	if len(xObjects) > 0 {
+		resources += " /XObject << " + strings.Join(xObjects, " ") + " >>"
+	}
+	objects = append(objects,
+		[]byte("<< /Type /Catalog /Pages 2 0 R >>"),
+		[]byte("<< /Type /Pages /Kids [3 0 R] /Count 1 >>"),
+		[]byte(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources <<%s >> /Contents 4 0 R >>",
+			pdfNumber(side), pdfNumber(side), resources)),
+		pdfStream("", p.content.Bytes()),
+	)
+	for _, spot := range p.spots {
+		f := spot.Fallback
+		// Type 2 function from no ink to the full CMYK fallback
+		objects = append(objects, []byte(fmt.Sprintf(
+			"[/Separation /%s /DeviceCMYK << /FunctionType 2 /Domain [0 1] /C0 [0 0 0 0] /C1 [%s %s %s %s] /N 1 >>]",
+			pdfName(spot.Name), unitValue(f.C), unitValue(f.M), unitValue(f.Y), unitValue(f.K))))
+	}
+	for _, shading := range p.shadings {
+		objects = append(objects, []byte(shading))
+	}
+	objects = append(objects, stateObjects...)
+	objects = append(objects, imageObjects...)
+
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = doc.Len()
+		fmt.Fprintf(&doc, "%d 0 obj\n", i+1)
+		doc.Write(object)
+		doc.WriteString("\nendobj\n")
+	}
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// fill paints the polygons with c. Translucent colors are painted with a
+// constant alpha, saved and restored around the fill.
+func (p *pdfPainter) fill(c color.Color, polygons ...[][2]float64) {
+	alpha := color.NRGBAModel.Convert(c).(color.NRGBA).A
+	if alpha < 0xff {
+		index := slices.IndexFunc(p.states, func(state pdfState) bool { return state.mask == nil && state.alpha == alpha })
+		if index < 0 {
+			index = len(p.states)
+			p.states = append(p.states, pdfState{alpha: alpha})
+		}
+		fmt.Fprintf(&p.content, "q\n/GS%d gs\n", index)
+	}
+	p.setColor(c)
+	p.path(polygons)
+	p.content.WriteString("f*\n")
+	if alpha < 0xff {
+		p.content.WriteString("Q\n")
+	}
+}
+
+// shade clips to the polygons and paints an axial or radial shading. PDF
+// shadings have no alpha, so translucent stops are painted through a soft
+// mask with a gray shading of the stop alphas.
+func (p *pdfPainter) shade(g *Gradient, geo gradientGeometry, polygons ...[][2]float64) {
+	stops := g.stops()
+	rgb := func(c color.Color) string {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		return fmt.Sprintf("[%s %s %s]", unitValue(n.R), unitValue(n.G), unitValue(n.B))
+	}
+	alpha := func(c color.Color) string {
+		return fmt.Sprintf("[%s]", unitValue(color.NRGBAModel.Convert(c).(color.NRGBA).A))
+	}
+	translucent := slices.ContainsFunc(stops, func(stop GradientStop) bool {
+		return color.NRGBAModel.Convert(stop.Color).(color.NRGBA).A < 0xff
+	})
+
+	p.content.WriteString("q\n")
+	p.path(polygons)
+	p.content.WriteString("W* n\n")
+	if translucent {
+		// The form is drawn in the user space current when the state is
+		// set, so the mask shading shares the gradient geometry
+		var minX, minY, maxX, maxY float64 = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, polygon := range polygons {
+			for _, pt := range polygon {
+				minX, minY = min(minX, pt[0]), min(minY, pt[1])
+				maxX, maxY = max(maxX, pt[0]), max(maxY, pt[1])
+			}
+		}
+		form := pdfStream(fmt.Sprintf(
+			"/Type /XObject /Subtype /Form /BBox [%s %s %s %s] /Group << /S /Transparency /CS /DeviceGray >> /Resources << /Shading << /Sh0 %s >> >>",
+			pdfNumber(minX), pdfNumber(minY), pdfNumber(maxX), pdfNumber(maxY), pdfShading(g, geo, stops, "/DeviceGray", alpha)),
+			[]byte("/Sh0 sh"))
+		fmt.Fprintf(&p.content, "/GS%d gs\n", len(p.states))
+		p.states = append(p.states, pdfState{mask: form})
+	}
+	fmt.Fprintf(&p.content, "/Sh%d sh\nQ\n", len(p.shadings))
+	p.shadings = append(p.shadings, pdfShading(g, geo, stops, "/DeviceRGB", rgb))
+}
+
+// pdfShading returns the shading dictionary of g in colorSpace, with the
+// components of each stop formatted by components
+func pdfShading(g *Gradient, geo gradientGeometry, stops []GradientStop, colorSpace string, components func(color.Color) string) string {
+	// One exponential interpolation per pair of stops, stitched together
+	var functions, bounds, encode []string
+	for i := 1; i < len(stops); i++ {
+		functions = append(functions, fmt.Sprintf("<< /FunctionType 2 /Domain [0 1] /C0 %s /C1 %s /N 1 >>", components(stops[i-1].Color), components(stops[i].Color)))
+		if i > 1 {
+			bounds = append(bounds, pdfNumber(stops[i-1].Offset))
+		}
+		encode = append(encode, "0 1")
+	}
+	function := functions[0]
+	if len(functions) > 1 {
+		function = fmt.Sprintf("<< /FunctionType 3 /Domain [0 1] /Functions [%s] /Bounds [%s] /Encode [%s] >>",
+			strings.Join(functions, " "), strings.Join(bounds, " "), strings.Join(encode, " "))
+	}
+	coords := fmt.Sprintf("/ShadingType 2 /Coords [%s %s %s %s]",
+		pdfNumber(geo.start[0]), pdfNumber(geo.start[1]), pdfNumber(geo.end[0]), pdfNumber(geo.end[1]))
+	if g.Kind == GradientRadial {
+		coords = fmt.Sprintf("/ShadingType 3 /Coords [%s %s 0 %s %s %s]",
+			pdfNumber(geo.start[0]), pdfNumber(geo.start[1]), pdfNumber(geo.start[0]), pdfNumber(geo.start[1]), pdfNumber(geo.radius))
+	}
+	return fmt.Sprintf("<< %s /ColorSpace %s /Function %s /Extend [true true] >>", coords, colorSpace, function)
+}
+
+func (p *pdfPainter) image(img image.Image, x, y, size float64, clip [][2]float64) {
+	p.content.WriteString("q\n")
+	p.path([][][2]float64{clip})
+	p.content.WriteString("W n\n")
+	// Image space has its origin at the bottom left, flip it back
+	x, y, w, h := fitLogo(img.Bounds(), x, y, size)
+	fmt.Fprintf(&p.content, "%s 0 0 %s %s %s cm\n/Im%d Do\nQ\n",
+		pdfNumber(w), pdfNumber(-h), pdfNumber(x), pdfNumber(y+h), len(p.images))
+	p.images = append(p.images, img)
+}
+
+func (p *pdfPainter) setColor(c color.Color) {
+	if spot, ok := ToSpot(c); ok {
+		index := -1
+		for i, known := range p.spots {
+			if known == spot {
+				index = i
+			}
+		}
+		if index < 0 {
+			index = len(p.spots)
+			p.spots = append(p.spots, spot)
+		}
+		fmt.Fprintf(&p.content, "/CS%d cs 1 scn\n", index)
+		return
+	}
+	if cmyk, ok := ToCMYK(c); ok {
+		fmt.Fprintf(&p.content, "%s %s %s %s k\n", unitValue(cmyk.C), unitValue(cmyk.M), unitValue(cmyk.Y), unitValue(cmyk.K))
+		return
+	}
+	rgb := color.NRGBAModel.Convert(c).(color.NRGBA)
+	fmt.Fprintf(&p.content, "%s %s %s rg\n", unitValue(rgb.R), unitValue(rgb.G), unitValue(rgb.B))
+}
+
+func (p *pdfPainter) path(polygons [][][2]float64) {
+	for _, polygon := range polygons {
+		for i, pt := range polygon {
+			op := "l"
+			if i == 0 {
+				op = "m"
+			}
+			fmt.Fprintf(&p.content, "%s %s %s\n", pdfNumber(pt[0]), pdfNumber(pt[1]), op)
+		}
+		p.content.WriteString("h\n")
+	}
+}
+
+// pdfImageData returns the RGB samples of img and its alpha samples, or
+// nil when img is opaque.
+func pdfImageData(img image.Image) (rgb, alpha []byte) {
+	bounds := img.Bounds()
+	rgb = make([]byte, 0, 3*bounds.Dx()*bounds.Dy())
+	alpha = make([]byte, 0, bounds.Dx()*bounds.Dy())
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			opaque = opaque && c.A == 0xff
+		}
+	}
+	if opaque {
+		alpha = nil
+	}
+	return rgb, alpha
+}
+
+// pdfStream returns a compressed stream object with the entries of dict.
+func pdfStream(dict string, data []byte) []byte {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< %s >>\nstream\n", strings.TrimSpace(fmt.Sprintf("%s /Length %d /Filter /FlateDecode", dict, compressed.Len())))
+	compressed.WriteTo(&b)
+	b.WriteString("\nendstream")
+	return b.Bytes()
+}
+
+// pdfNumber formats v with at most 4 decimals and no exponent.
+func pdfNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+}
+
+// pdfName escapes the characters not allowed in a PDF name object.
+func pdfName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte("#/%()<>[]{}", c) >= 0 {
+			fmt.Fprintf(&b, "#%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
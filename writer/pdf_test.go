@@ -0,0 +1,157 @@
+package writer
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pdfObjects checks the cross-reference table of doc and returns the
+// bodies of its objects, indexed by object number
+func pdfObjects(t *testing.T, doc []byte) map[int][]byte {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(doc)
+	if m == nil {
+		t.Fatalf("no startxref at the end of:\n%s", doc)
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(doc[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to the xref table", xref)
+	}
+	var first, count int
+	if _, err := fmt.Sscanf(string(doc[xref:]), "xref\n%d %d\n", &first, &count); err != nil {
+		t.Fatal(err)
+	}
+	entries := regexp.MustCompile(`(\d{10}) (\d{5}) ([fn]) \n`).FindAllSubmatch(doc[xref:], -1)
+	if len(entries) != count {
+		t.Fatalf("xref lists %d entries, want %d", len(entries), count)
+	}
+	objects := map[int][]byte{}
+	for i, entry := range entries[1:] {
+		n := first + i + 1
+		offset, _ := strconv.Atoi(string(entry[1]))
+		header := fmt.Sprintf("%d 0 obj\n", n)
+		if !bytes.HasPrefix(doc[offset:], []byte(header)) {
+			t.Errorf("object %d: offset %d points to %q", n, offset, doc[offset:min(offset+20, len(doc))])
+			continue
+		}
+		body := doc[offset+len(header):]
+		objects[n] = body[:bytes.Index(body, []byte("\nendobj\n"))]
+	}
+	return objects
+}
+
+// pdfStreamData returns the decompressed data of a stream object
+func pdfStreamData(t *testing.T, object []byte) string {
+	t.Helper()
+	start := bytes.Index(object, []byte("stream\n"))
+	end := bytes.LastIndex(object, []byte("\nendstream"))
+	if start < 0 || end < start {
+		t.Fatalf("not a stream: %q", object)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(object[start+len("stream\n") : end]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestPDFPrintColors(t *testing.T) {
+	s := blankScene(21)
+	s.Modules[10][10].Dark = true
+	s.Modules[10][12].Dark = true
+	s.Modules[10][12].Role = RoleTiming
+	s.Color = color.CMYK{0, 0, 0, 0xff}
+	s.Colors.Timing = SpotColor{Name: "PANTONE 185 C", Fallback: color.CMYK{0, 0xff, 0xcc, 0}}
+	var buf bytes.Buffer
+	if err := (PDFRenderer{}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	objects := pdfObjects(t, buf.Bytes())
+	if len(objects) != 5 {
+		t.Fatalf("%d objects, want the catalog, pages, page, content and color space", len(objects))
+	}
+	if page := string(objects[3]); !strings.Contains(page, "/ColorSpace << /CS0 5 0 R >>") {
+		t.Errorf("page resources without the separation: %s", page)
+	}
+	if cs := string(objects[5]); cs != "[/Separation /PANTONE#20185#20C /DeviceCMYK << /FunctionType 2 /Domain [0 1] /C0 [0 0 0 0] /C1 [0 1 0.8 0] /N 1 >>]" {
+		t.Errorf("separation color space %s", cs)
+	}
+	content := pdfStreamData(t, objects[4])
+	for _, want := range []string{"0 0 0 1 k\n", "/CS0 cs 1 scn\n"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content stream without %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestPDFTransparency(t *testing.T) {
+	s := blankScene(21)
+	s.Modules[10][10].Dark = true
+	s.Modules[10][12].Dark = true
+	s.Modules[10][12].Role = RoleTiming
+	s.Background = color.NRGBA{0xff, 0xff, 0xff, 0x80}
+	s.Colors.Data = color.NRGBA{0, 0, 0xff, 0x80}
+	s.Gradient = &Gradient{Stops: []GradientStop{{0, color.Black}, {1, color.NRGBA{0, 0, 0xff, 0x40}}}}
+	var buf bytes.Buffer
+	if err := (PDFRenderer{}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	objects := pdfObjects(t, buf.Bytes())
+
+	// The background and the data modules share one constant alpha state,
+	// the gradient painting the timing module gets a soft mask
+	if want := "/ExtGState << /GS0 6 0 R /GS1 7 0 R >>"; !bytes.Contains(objects[3], []byte(want)) {
+		t.Errorf("page resources %s, want %s", objects[3], want)
+	}
+	if got, want := string(objects[6]), "<< /Type /ExtGState /ca 0.502 /CA 0.502 >>"; got != want {
+		t.Errorf("alpha state %s, want %s", got, want)
+	}
+	if got, want := string(objects[7]), "<< /Type /ExtGState /SMask << /Type /Mask /S /Luminosity /G 8 0 R >> >>"; got != want {
+		t.Errorf("mask state %s, want %s", got, want)
+	}
+	for _, want := range []string{"/Subtype /Form", "/Group << /S /Transparency /CS /DeviceGray >>", "/ColorSpace /DeviceGray", "/C0 [1] /C1 [0.251]"} {
+		if !bytes.Contains(objects[8], []byte(want)) {
+			t.Errorf("no %s in the mask form %s", want, objects[8])
+		}
+	}
+	if got := pdfStreamData(t, objects[8]); got != "/Sh0 sh" {
+		t.Errorf("mask form draws %q", got)
+	}
+
+	content := pdfStreamData(t, objects[4])
+	for _, want := range []string{"q\n/GS0 gs\n1 1 1 rg\n", "q\n/GS0 gs\n0 0 1 rg\n", "W* n\n/GS1 gs\n/Sh0 sh\nQ\n"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("no %q in the content:\n%s", want, content)
+		}
+	}
+	if n := strings.Count(content, "q\n") - strings.Count(content, "Q\n"); n != 0 {
+		t.Errorf("%d unbalanced q operators", n)
+	}
+}
+
+func TestPDFLogoFit(t *testing.T) {
+	s := blankScene(21)
+	s.QuietZone = 0
+	s.Logo = &Logo{Image: image.NewGray(image.Rect(0, 0, 20, 10)), X: 8, Y: 8, Size: 5, Plate: PlateSquare}
+	var buf bytes.Buffer
+	if err := (PDFRenderer{}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	content := pdfStreamData(t, pdfObjects(t, buf.Bytes())[4])
+	// Letterboxed into the logo square, flipped into image space
+	if want := "5 0 0 -2.5 8 11.75 cm\n/Im0 Do\n"; !strings.Contains(content, want) {
+		t.Errorf("no %q in the content:\n%s", want, content)
+	}
+}
@@ -0,0 +1,209 @@
+package writer
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"slices"
+)
+
+const (
+	defaultModuleMM = 1.0
+	// HP-GL plotter units per millimetre
+	hpglUnitsPerMM = 40
+)
+
+// PlotOptions describes the outlines written by DXFRenderer and
+// HPGLRenderer. All lengths are in millimetres and the origin is the lower
+// left corner of the quiet zone.
+type PlotOptions struct {
+	ModuleSize float64
+	// HatchSpacing adds horizontal fill lines inside dark regions, spaced
+	// by the given distance. Zero disables hatching.
+	HatchSpacing float64
+}
+
+// DXFRenderer writes the outlines of the dark regions as closed polylines
+// in an AutoCAD R12 DXF file. Outlines go to the layer QR and hatch lines
+// to the layer HATCH.
+type DXFRenderer struct {
+	PlotOptions
+}
+
+// HPGLRenderer writes the outlines of the dark regions with pen 1 and the
+// hatch lines with pen 2 as HP-GL commands.
+type HPGLRenderer struct {
+	PlotOptions
+}
+
+// plotLine is a polyline in millimetres
+type plotLine struct {
+	points [][2]float64
+	closed bool
+}
+
+// plotGeometry converts the dark region outlines and the optional hatch
+// lines to millimetres, flipping the y axis so it points up. The modules
+// cleared around the logo stay empty.
+func plotGeometry(s *Scene, opts PlotOptions) (outlines, hatches []plotLine) {
+	size := opts.ModuleSize
+	total := float64(s.Size() + 2*s.QuietZone)
+	toMM := func(p image.Point) [2]float64 {
+		return [2]float64{
+			float64(p.X+s.QuietZone) * size,
+			(total - float64(p.Y+s.QuietZone)) * size,
+		}
+	}
+
+	dark := func(x, y int) bool { return s.Dark(x, y) && !s.Cleared(x, y) }
+	for _, contour := range traceContours(s.Size(), dark) {
+		line := plotLine{closed: true}
+		for _, p := range contour {
+			line.points = append(line.points, toMM(p))
+		}
+		outlines = append(outlines, line)
+	}
+
+	if opts.HatchSpacing <= 0 {
+		return outlines, hatches
+	}
+	// Hatch lines alternate direction so the tool travels in a zig-zag
+	reverse := false
+	height := float64(s.Size()) * size
+	for offset := opts.HatchSpacing / 2; offset < height; offset += opts.HatchSpacing {
+		row := int(offset / size)
+		y := (total-float64(s.QuietZone))*size - offset
+		modules := s.Modules[row]
+		if s.Logo != nil {
+			modules = slices.Clone(modules)
+			for x := range modules {
+				modules[x].Dark = dark(x, row)
+			}
+		}
+		runs := darkRuns(modules)
+		for i := range runs {
+			run := runs[i]
+			if reverse {
+				run = runs[len(runs)-1-i]
+			}
+			x0 := float64(run[0]+s.QuietZone) * size
+			x1 := float64(run[1]+s.QuietZone) * size
+			if reverse {
+				x0, x1 = x1, x0
+			}
+			hatches = append(hatches, plotLine{points: [][2]float64{{x0, y}, {x1, y}}})
+		}
+		reverse = !reverse
+	}
+	return outlines, hatches
+}
+
+// Render writes the DXF file to w.
+func (r DXFRenderer) Render(w io.Writer, s *Scene) error {
+	if r.ModuleSize <= 0 {
+		r.ModuleSize = defaultModuleMM
+	}
+	outlines, hatches := plotGeometry(s, r.PlotOptions)
+	total := float64(s.Size()+2*s.QuietZone) * r.ModuleSize
+
+	bw := bufio.NewWriter(w)
+	group := func(code int, value any) {
+		switch v := value.(type) {
+		case float64:
+			fmt.Fprintf(bw, "%d\n%s\n", code, formatMM(v))
+		default:
+			fmt.Fprintf(bw, "%d\n%v\n", code, v)
+		}
+	}
+
+	group(0, "SECTION")
+	group(2, "HEADER")
+	group(9, "$ACADVER")
+	group(1, "AC1009")
+	group(9, "$MEASUREMENT")
+	group(70, 1)
+	group(9, "$EXTMIN")
+	group(10, 0.)
+	group(20, 0.)
+	group(9, "$EXTMAX")
+	group(10, total)
+	group(20, total)
+	group(0, "ENDSEC")
+
+	group(0, "SECTION")
+	group(2, "ENTITIES")
+	for _, line := range outlines {
+		group(0, "POLYLINE")
+		group(8, "QR")
+		group(66, 1)
+		// R12 polylines carry a dummy location
+		group(10, 0.)
+		group(20, 0.)
+		group(30, 0.)
+		group(70, 1) // closed
+		for _, p := range line.points {
+			group(0, "VERTEX")
+			group(8, "QR")
+			group(10, p[0])
+			group(20, p[1])
+		}
+		group(0, "SEQEND")
+		group(8, "QR")
+	}
+	for _, line := range hatches {
+		group(0, "LINE")
+		group(8, "HATCH")
+		group(10, line.points[0][0])
+		group(20, line.points[0][1])
+		group(11, line.points[1][0])
+		group(21, line.points[1][1])
+	}
+	group(0, "ENDSEC")
+	group(0, "EOF")
+	return bw.Flush()
+}
+
+// Render writes the HP-GL commands to w.
+func (r HPGLRenderer) Render(w io.Writer, s *Scene) error {
+	if r.ModuleSize <= 0 {
+		r.ModuleSize = defaultModuleMM
+	}
+	outlines, hatches := plotGeometry(s, r.PlotOptions)
+
+	bw := bufio.NewWriter(w)
+	toUnits := func(p [2]float64) string {
+		return fmt.Sprintf("%d,%d", int(math.Round(p[0]*hpglUnitsPerMM)), int(math.Round(p[1]*hpglUnitsPerMM)))
+	}
+	draw := func(line plotLine) {
+		fmt.Fprintf(bw, "PU%s;PD", toUnits(line.points[0]))
+		for i, p := range line.points[1:] {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			bw.WriteString(toUnits(p))
+		}
+		if line.closed {
+			fmt.Fprintf(bw, ",%s", toUnits(line.points[0]))
+		}
+		bw.WriteString(";\n")
+	}
+
+	bw.WriteString("IN;SP1;\n")
+	for _, line := range outlines {
+		draw(line)
+	}
+	if len(hatches) > 0 {
+		bw.WriteString("SP2;\n")
+		for _, line := range hatches {
+			draw(line)
+		}
+	}
+	bw.WriteString("PU;SP0;\n")
+	return bw.Flush()
+}
+
+func formatMM(v float64) string {
+	return fmt.Sprintf("%.4f", v)
+}
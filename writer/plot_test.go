@@ -0,0 +1,146 @@
+package writer
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// dxfPairs splits a DXF file into its group code and value lines
+func dxfPairs(t *testing.T, doc string) [][2]string {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(doc, "\n"), "\n")
+	if len(lines)%2 != 0 {
+		t.Fatalf("odd number of lines in:\n%s", doc)
+	}
+	pairs := make([][2]string, 0, len(lines)/2)
+	for i := 0; i < len(lines); i += 2 {
+		pairs = append(pairs, [2]string{lines[i], lines[i+1]})
+	}
+	return pairs
+}
+
+func TestDXFRenderer(t *testing.T) {
+	s := sceneFromRows("#.", "##")
+	s.QuietZone = 1
+	var buf bytes.Buffer
+	if err := (DXFRenderer{PlotOptions{ModuleSize: 2, HatchSpacing: 1}}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	pairs := dxfPairs(t, buf.String())
+	if strings.Contains(buf.String(), "$INSUNITS") {
+		t.Error("R12 header with $INSUNITS")
+	}
+
+	// The extents span the quiet zone, 4 modules of 2 mm
+	header := [][2]string{
+		{"0", "SECTION"}, {"2", "HEADER"},
+		{"9", "$ACADVER"}, {"1", "AC1009"},
+		{"9", "$MEASUREMENT"}, {"70", "1"},
+		{"9", "$EXTMIN"}, {"10", "0.0000"}, {"20", "0.0000"},
+		{"9", "$EXTMAX"}, {"10", "8.0000"}, {"20", "8.0000"},
+		{"0", "ENDSEC"},
+	}
+	if !slices.Equal(pairs[:len(header)], header) {
+		t.Errorf("header %v, want %v", pairs[:len(header)], header)
+	}
+
+	// One closed polyline around the L, with y pointing up from the
+	// bottom of the quiet zone
+	vertex := func(x, y string) [][2]string {
+		return [][2]string{{"0", "VERTEX"}, {"8", "QR"}, {"10", x}, {"20", y}}
+	}
+	line := func(x0, y0, x1, y1 string) [][2]string {
+		return [][2]string{{"0", "LINE"}, {"8", "HATCH"}, {"10", x0}, {"20", y0}, {"11", x1}, {"21", y1}}
+	}
+	entities := slices.Concat(
+		[][2]string{{"0", "SECTION"}, {"2", "ENTITIES"}},
+		[][2]string{{"0", "POLYLINE"}, {"8", "QR"}, {"66", "1"}, {"10", "0.0000"}, {"20", "0.0000"}, {"30", "0.0000"}, {"70", "1"}},
+		vertex("2.0000", "6.0000"),
+		vertex("4.0000", "6.0000"),
+		vertex("4.0000", "4.0000"),
+		vertex("6.0000", "4.0000"),
+		vertex("6.0000", "2.0000"),
+		vertex("2.0000", "2.0000"),
+		[][2]string{{"0", "SEQEND"}, {"8", "QR"}},
+		// Hatch lines every millimetre, in alternating directions
+		line("2.0000", "5.5000", "4.0000", "5.5000"),
+		line("4.0000", "4.5000", "2.0000", "4.5000"),
+		line("2.0000", "3.5000", "6.0000", "3.5000"),
+		line("6.0000", "2.5000", "2.0000", "2.5000"),
+		[][2]string{{"0", "ENDSEC"}, {"0", "EOF"}},
+	)
+	if got := pairs[len(header):]; !slices.Equal(got, entities) {
+		t.Errorf("entities %v, want %v", got, entities)
+	}
+}
+
+func TestHPGLRenderer(t *testing.T) {
+	s := sceneFromRows("#.", "##")
+	s.QuietZone = 1
+	// 40 plotter units per millimetre, pen 1 for the outline and pen 2 for
+	// the hatch lines
+	tests := []struct {
+		hatch float64
+		want  string
+	}{
+		{0, "IN;SP1;\nPU80,240;PD160,240,160,160,240,160,240,80,80,80,80,240;\nPU;SP0;\n"},
+		{1, "IN;SP1;\nPU80,240;PD160,240,160,160,240,160,240,80,80,80,80,240;\n" +
+			"SP2;\nPU80,220;PD160,220;\nPU160,180;PD80,180;\nPU80,140;PD240,140;\nPU240,100;PD80,100;\n" +
+			"PU;SP0;\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := (HPGLRenderer{PlotOptions{ModuleSize: 2, HatchSpacing: tt.hatch}}).Render(&buf, s); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("hatch %g:\n%s\nwant:\n%s", tt.hatch, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestPlotHatchZigZag(t *testing.T) {
+	s := sceneFromRows("#.#", "#.#")
+	s.QuietZone = 0
+	_, hatches := plotGeometry(s, PlotOptions{ModuleSize: 1, HatchSpacing: 1})
+	// The second row runs back from right to left, its runs reversed
+	want := [][][2]float64{
+		{{0, 1.5}, {1, 1.5}},
+		{{2, 1.5}, {3, 1.5}},
+		{{3, 0.5}, {2, 0.5}},
+		{{1, 0.5}, {0, 0.5}},
+	}
+	if len(hatches) != len(want) {
+		t.Fatalf("%d hatch lines, want %d", len(hatches), len(want))
+	}
+	for i, line := range hatches {
+		if line.closed || !slices.Equal(line.points, want[i]) {
+			t.Errorf("hatch line %d: %v closed %v, want %v", i, line.points, line.closed, want[i])
+		}
+	}
+}
+
+func TestPlotClearedModules(t *testing.T) {
+	// The modules cleared by a logo are plotted as light modules
+	cleared := sceneFromRows("###", "###", "###")
+	cleared.Logo = &Logo{Cleared: [][]bool{{false, false, false}, {false, true, false}, {false, false, false}}}
+	light := sceneFromRows("###", "#.#", "###")
+	opts := PlotOptions{ModuleSize: 1, HatchSpacing: 0.5}
+	for name, r := range map[string]Renderer{
+		"dxf":  DXFRenderer{opts},
+		"hpgl": HPGLRenderer{opts},
+	} {
+		var got, want bytes.Buffer
+		if err := r.Render(&got, cleared); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Render(&want, light); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("%s with cleared modules:\n%s\nwant:\n%s", name, got.String(), want.String())
+		}
+	}
+}
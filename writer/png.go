@@ -0,0 +1,158 @@
+package writer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"slices"
+)
+
+// vertical samples per pixel row, horizontal coverage is computed exactly
+const scanlineSamples = 4
+
+// colors precomputed along a gradient, more than 8 bit channels can tell
+// apart
+const gradientSteps = 1024
+
+// PNGRenderer draws the scene as an antialiased PNG image.
+type PNGRenderer struct {
+	// ModuleSize is the side of a module in pixels.
+	ModuleSize int
+}
+
+// Render encodes the image to w.
+func (r PNGRenderer) Render(w io.Writer, s *Scene) error {
+	img, err := r.Image(s)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// Image draws the scene, including its quiet zone, in memory.
+func (r PNGRenderer) Image(s *Scene) (*image.RGBA, error) {
+	if r.ModuleSize < 1 {
+		r.ModuleSize = defaultModulePixels
+	}
+	dim := (s.Size() + 2*s.QuietZone) * r.ModuleSize
+	p := &rasterPainter{
+		dst:   image.NewRGBA(image.Rect(0, 0, dim, dim)),
+		scale: float64(r.ModuleSize),
+	}
+	if err := paintScene(p, s); err != nil {
+		return nil, err
+	}
+	return p.dst, nil
+}
+
+// rasterPainter draws on an image with scale pixels per module.
+type rasterPainter struct {
+	dst   *image.RGBA
+	scale float64
+}
+
+func (p *rasterPainter) fill(c color.Color, polygons ...[][2]float64) {
+	mask := p.coverage(polygons)
+	draw.DrawMask(p.dst, mask.Rect, image.NewUniform(c), image.Point{}, mask, mask.Rect.Min, draw.Over)
+}
+
+func (p *rasterPainter) shade(g *Gradient, geo gradientGeometry, polygons ...[][2]float64) {
+	mask := p.coverage(polygons)
+	// A lookup table avoids interpolating between the stops per pixel
+	var ramp [gradientSteps]color.NRGBA
+	for i := range ramp {
+		ramp[i] = g.At(float64(i) / (gradientSteps - 1))
+	}
+	src := image.NewNRGBA(mask.Rect)
+	for py := mask.Rect.Min.Y; py < mask.Rect.Max.Y; py++ {
+		for px := mask.Rect.Min.X; px < mask.Rect.Max.X; px++ {
+			t := g.offset(geo, (float64(px)+0.5)/p.scale, (float64(py)+0.5)/p.scale)
+			src.SetNRGBA(px, py, ramp[int(math.Round(t*(gradientSteps-1)))])
+		}
+	}
+	draw.DrawMask(p.dst, mask.Rect, src, mask.Rect.Min, mask, mask.Rect.Min, draw.Over)
+}
+
+func (p *rasterPainter) image(img image.Image, x, y, size float64, clip [][2]float64) {
+	mask := p.coverage([][][2]float64{clip})
+	src := img.Bounds()
+	fx, fy, w, h := fitLogo(src, x, y, size)
+	x0, y0 := fx*p.scale, fy*p.scale
+	w, h = w*p.scale, h*p.scale
+
+	// Nearest neighbour resampling of the logo into the clip bounds
+	scaled := image.NewRGBA(mask.Rect)
+	for py := mask.Rect.Min.Y; py < mask.Rect.Max.Y; py++ {
+		sy := src.Min.Y + int(math.Floor((float64(py)+0.5-y0)/h*float64(src.Dy())))
+		for px := mask.Rect.Min.X; px < mask.Rect.Max.X; px++ {
+			sx := src.Min.X + int(math.Floor((float64(px)+0.5-x0)/w*float64(src.Dx())))
+			if image.Pt(sx, sy).In(src) {
+				scaled.Set(px, py, img.At(sx, sy))
+			}
+		}
+	}
+	draw.DrawMask(p.dst, mask.Rect, scaled, mask.Rect.Min, mask, mask.Rect.Min, draw.Over)
+}
+
+// coverage rasterizes polygons with the even-odd rule into an alpha mask
+// covering their bounding box.
+func (p *rasterPainter) coverage(polygons [][][2]float64) *image.Alpha {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	scaled := make([][][2]float64, len(polygons))
+	for i, polygon := range polygons {
+		scaled[i] = make([][2]float64, len(polygon))
+		for j, pt := range polygon {
+			x, y := pt[0]*p.scale, pt[1]*p.scale
+			scaled[i][j] = [2]float64{x, y}
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+	}
+	bounds := image.Rect(
+		int(math.Floor(minX)), int(math.Floor(minY)),
+		int(math.Ceil(maxX)), int(math.Ceil(maxY)),
+	).Intersect(p.dst.Rect)
+	mask := image.NewAlpha(bounds)
+	if bounds.Empty() {
+		return mask
+	}
+
+	acc := make([]float64, bounds.Dx())
+	var crossings []float64
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		clear(acc)
+		for sample := range scanlineSamples {
+			sy := float64(py) + (float64(sample)+0.5)/scanlineSamples
+			crossings = crossings[:0]
+			for _, polygon := range scaled {
+				for i, a := range polygon {
+					b := polygon[(i+1)%len(polygon)]
+					if (a[1] <= sy) != (b[1] <= sy) {
+						crossings = append(crossings, a[0]+(sy-a[1])/(b[1]-a[1])*(b[0]-a[0]))
+					}
+				}
+			}
+			slices.Sort(crossings)
+			for i := 0; i+1 < len(crossings); i += 2 {
+				addSpan(acc, crossings[i]-float64(bounds.Min.X), crossings[i+1]-float64(bounds.Min.X))
+			}
+		}
+		for x, a := range acc {
+			mask.Pix[(py-bounds.Min.Y)*mask.Stride+x] = uint8(math.Round(min(1, a/scanlineSamples) * 0xff))
+		}
+	}
+	return mask
+}
+
+// addSpan adds the horizontal coverage of [x0, x1) to the pixels of acc
+func addSpan(acc []float64, x0, x1 float64) {
+	x0 = max(x0, 0)
+	x1 = min(x1, float64(len(acc)))
+	for px := int(x0); float64(px) < x1; px++ {
+		acc[px] += min(x1, float64(px+1)) - max(x0, float64(px))
+	}
+}
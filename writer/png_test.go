@@ -0,0 +1,86 @@
+package writer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestPNGModules(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	for _, shape := range []Shape{ShapeSquare, ShapeCircle} {
+		s := blankScene(21)
+		s.Shape = shape
+		s.QuietZone = 2
+		s.Color = red
+		s.Modules[10][10].Dark = true
+		s.Modules[10][11].Dark = true
+		img, err := (PNGRenderer{ModuleSize: 4}).Image(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := img.Bounds().Dx(); size != (21+4)*4 {
+			t.Errorf("%s: image of %d pixels", shape, size)
+		}
+		// pixel returns the color at (dx, dy) pixels into the module at
+		// column x and row y
+		pixel := func(x, y, dx, dy int) color.RGBA {
+			return img.RGBAAt((x+2)*4+dx, (y+2)*4+dy)
+		}
+		tests := []struct {
+			x, y, dx, dy int
+			want         color.RGBA
+		}{
+			{10, 10, 2, 2, red},
+			{11, 10, 1, 1, red},
+			{12, 10, 2, 2, white},
+			{10, 11, 2, 2, white},
+			{-2, -2, 0, 0, white},
+		}
+		for _, tt := range tests {
+			if got := pixel(tt.x, tt.y, tt.dx, tt.dy); got != tt.want {
+				t.Errorf("%s: module (%d, %d) at (%d, %d) is %v, want %v", shape, tt.x, tt.y, tt.dx, tt.dy, got, tt.want)
+			}
+		}
+		// Square modules fill their corners, circles leave them out
+		corner := pixel(10, 10, 0, 0)
+		if (corner == red) != (shape == ShapeSquare) {
+			t.Errorf("%s: corner pixel %v", shape, corner)
+		}
+	}
+}
+
+func TestPNGLogoFit(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	// A logo twice as wide as high is letterboxed into its square, as
+	// SVG draws it
+	logo := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(logo, logo.Rect, image.NewUniform(red), image.Point{}, draw.Src)
+	s := blankScene(21)
+	s.QuietZone = 0
+	s.Logo = &Logo{Image: logo, X: 8, Y: 8, Size: 5, Plate: PlateSquare}
+	img, err := (PNGRenderer{ModuleSize: 4}).Image(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The logo spans rows 9.25 to 11.75 and columns 8 to 13
+	tests := []struct {
+		x, y float64
+		want color.RGBA
+	}{
+		{10.5, 8.5, white},
+		{10.5, 9.5, red},
+		{8.1, 10.5, red},
+		{12.9, 10.5, red},
+		{10.5, 11.5, red},
+		{10.5, 12.5, white},
+	}
+	for _, tt := range tests {
+		if got := img.RGBAAt(int(tt.x*4), int(tt.y*4)); got != tt.want {
+			t.Errorf("pixel at module (%g, %g) is %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	defaultPrinterDPI = 203
+	// rows sent per ESC/POS raster command, small enough for printers with
+	// little buffer memory
+	escposBandHeight = 256
+)
+
+// ZPLRenderer writes a complete ZPL II label with the symbol either as a
+// ^GF graphic field or as a native ^BQ barcode.
+type ZPLRenderer struct {
+	// DPI is the printer resolution, usually 203, 300 or 600.
+	DPI int
+	// ModuleSize is the side of a module in millimetres. It is rounded to
+	// a whole number of printer dots.
+	ModuleSize float64
+	// Native emits the printer's own ^BQ QR Code command instead of a
+	// graphic field. The scene Data and Level are required in that case,
+	// so it does not work for imported matrices.
+	Native bool
+	// X and Y are the label coordinates of the symbol in dots.
+	X, Y int
+}
+
+// ESCPOSRenderer writes the symbol as ESC/POS raster bit images (GS v 0),
+// centered on the paper.
+type ESCPOSRenderer struct {
+	// DPI is the printer resolution, 203 for most receipt printers.
+	DPI int
+	// ModuleSize is the side of a module in millimetres. It is rounded to
+	// a whole number of printer dots.
+	ModuleSize float64
+	// Cut feeds the paper and performs a partial cut after the symbol.
+	Cut bool
+}
+
+// moduleDots converts a module size in millimetres to printer dots, at
+// least one
+func moduleDots(moduleSize float64, dpi int) int {
+	if moduleSize <= 0 {
+		moduleSize = defaultModuleMM
+	}
+	if dpi <= 0 {
+		dpi = defaultPrinterDPI
+	}
+	return max(1, int(math.Round(moduleSize*float64(dpi)/25.4)))
+}
+
+// packRows returns the image rows as 1 bit per pixel, most significant bit
+// first, with 1 for dark pixels
+func packRows(img *image.Paletted) [][]byte {
+	bounds := img.Bounds()
+	bytesPerRow := (bounds.Dx() + 7) / 8
+	rows := make([][]byte, bounds.Dy())
+	for y := range rows {
+		row := make([]byte, bytesPerRow)
+		for x := range bounds.Dx() {
+			if img.ColorIndexAt(bounds.Min.X+x, bounds.Min.Y+y) == 1 {
+				row[x/8] |= 0x80 >> (x % 8)
+			}
+		}
+		rows[y] = row
+	}
+	return rows
+}
+
+// Render writes the label to w.
+func (r ZPLRenderer) Render(w io.Writer, s *Scene) error {
+	dots := moduleDots(r.ModuleSize, r.DPI)
+	bw := bufio.NewWriter(w)
+	bw.WriteString("^XA\n")
+	fmt.Fprintf(bw, "^FO%d,%d", r.X, r.Y)
+
+	if r.Native {
+		// The printer encodes the data again, the modules are not sent
+		if s.Data == "" {
+			return errors.New("^BQ needs the encoded data, which imported matrices do not have, use the ^GF graphic field instead")
+		}
+		level := strings.ToUpper(s.Level)
+		if !strings.Contains("LMQH", level) || len(level) != 1 {
+			return fmt.Errorf("invalid error correction level %q for ^BQ", s.Level)
+		}
+		// ^BQ magnification goes from 1 to 10 dots per module
+		magnification := min(dots, 10)
+		fmt.Fprintf(bw, "^BQN,2,%d\n", magnification)
+		// ^FH lets the data contain the ZPL control characters
+		fmt.Fprintf(bw, "^FH_^FD%sA,%s^FS\n", level, escapeZPL(s.Data))
+	} else {
+		rows := packRows(rasterize(s, dots))
+		bytesPerRow := len(rows[0])
+		total := bytesPerRow * len(rows)
+		fmt.Fprintf(bw, "^GFA,%d,%d,%d,\n", total, total, bytesPerRow)
+		var previous []byte
+		for _, row := range rows {
+			bw.WriteString(compressZPLRow(row, previous))
+			bw.WriteByte('\n')
+			previous = row
+		}
+		bw.WriteString("^FS\n")
+	}
+	bw.WriteString("^XZ\n")
+	return bw.Flush()
+}
+
+// compressZPLRow encodes a graphic field row in hexadecimal using the ZPL
+// ASCII compression shortcuts: ':' repeats the previous row and ',' fills
+// the rest of the row with zeros.
+func compressZPLRow(row, previous []byte) string {
+	if previous != nil && bytes.Equal(row, previous) {
+		return ":"
+	}
+	end := len(row)
+	for end > 0 && row[end-1] == 0 {
+		end--
+	}
+	encoded := fmt.Sprintf("%X", row[:end])
+	if end < len(row) {
+		encoded += ","
+	}
+	return encoded
+}
+
+func escapeZPL(data string) string {
+	var b strings.Builder
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '^', '~', '_':
+			fmt.Fprintf(&b, "_%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Render writes the printer commands to w.
+func (r ESCPOSRenderer) Render(w io.Writer, s *Scene) error {
+	dots := moduleDots(r.ModuleSize, r.DPI)
+	rows := packRows(rasterize(s, dots))
+	bytesPerRow := len(rows[0])
+
+	bw := bufio.NewWriter(w)
+	bw.Write([]byte{0x1b, '@'})    // ESC @ initialize
+	bw.Write([]byte{0x1b, 'a', 1}) // ESC a 1 center
+	for start := 0; start < len(rows); start += escposBandHeight {
+		band := rows[start:min(start+escposBandHeight, len(rows))]
+		// GS v 0 m xL xH yL yH
+		bw.Write([]byte{0x1d, 'v', '0', 0,
+			byte(bytesPerRow), byte(bytesPerRow >> 8),
+			byte(len(band)), byte(len(band) >> 8),
+		})
+		for _, row := range band {
+			bw.Write(row)
+		}
+	}
+	bw.Write([]byte{0x1b, 'a', 0}) // ESC a 0 left
+	bw.Write([]byte{0x1b, 'd', 3}) // ESC d 3 feed 3 lines
+	if r.Cut {
+		bw.Write([]byte{0x1d, 'V', 66, 0}) // GS V 66 0 feed and partial cut
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,83 @@
+package writer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompressZPLRow(t *testing.T) {
+	tests := []struct {
+		row, previous []byte
+		want          string
+	}{
+		{[]byte{0xff, 0x80}, nil, "FF80"},
+		{[]byte{0x0f, 0, 0}, nil, "0F,"},
+		{[]byte{0, 0}, nil, ","},
+		{[]byte{0xf0, 0x01}, []byte{0xf0, 0x01}, ":"},
+		{[]byte{0xf0, 0x01}, []byte{0xf0, 0x00}, "F001"},
+	}
+	for _, tt := range tests {
+		if got := compressZPLRow(tt.row, tt.previous); got != tt.want {
+			t.Errorf("compressZPLRow(% X, % X) = %q, want %q", tt.row, tt.previous, got, tt.want)
+		}
+	}
+}
+
+func TestZPLGraphicField(t *testing.T) {
+	s := sceneFromRows("#.", ".#")
+	s.QuietZone = 1
+	var buf bytes.Buffer
+	// 2 dots per module: 8 dots wide, one byte per row
+	if err := (ZPLRenderer{DPI: 254, ModuleSize: 0.2}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	want := "^XA\n^FO0,0^GFA,8,8,1,\n,\n:\n30\n:\n0C\n:\n,\n:\n^FS\n^XZ\n"
+	if got := buf.String(); got != want {
+		t.Errorf("label\n%q, want\n%q", got, want)
+	}
+}
+
+func TestZPLNative(t *testing.T) {
+	s := sceneFromRows("#.", ".#")
+	s.Level = "m"
+	s.Data = "a^b"
+	var buf bytes.Buffer
+	if err := (ZPLRenderer{Native: true}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "^FH_^FDMA,a_5Eb^FS") {
+		t.Errorf("label without the escaped data:\n%s", buf.String())
+	}
+
+	// An imported matrix has no data for the printer to encode
+	s.Data = ""
+	if err := (ZPLRenderer{Native: true}).Render(&buf, s); err == nil {
+		t.Error("native label written without data")
+	}
+}
+
+func TestESCPOSBands(t *testing.T) {
+	s := blankScene(21)
+	s.Modules[0][0].Dark = true
+	// 10 dots per module: 290 rows of 37 bytes, in bands of 256 and 34 rows
+	var buf bytes.Buffer
+	if err := (ESCPOSRenderer{DPI: 254, ModuleSize: 1, Cut: true}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.Bytes()
+	if !bytes.HasPrefix(out, []byte{0x1b, '@', 0x1b, 'a', 1}) {
+		t.Fatalf("commands start with % X", out[:5])
+	}
+	out = out[5:]
+	for _, rows := range []int{256, 34} {
+		header := []byte{0x1d, 'v', '0', 0, 37, 0, byte(rows), byte(rows >> 8)}
+		if !bytes.HasPrefix(out, header) {
+			t.Fatalf("band of %d rows starts with % X, want % X", rows, out[:min(len(out), 8)], header)
+		}
+		out = out[len(header)+37*rows:]
+	}
+	if want := []byte{0x1b, 'a', 0, 0x1b, 'd', 3, 0x1d, 'V', 66, 0}; !bytes.Equal(out, want) {
+		t.Errorf("commands end with % X, want % X", out, want)
+	}
+}
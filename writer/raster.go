@@ -0,0 +1,35 @@
+package writer
+
+import (
+	"image"
+	"image/color"
+)
+
+const defaultQuietZone = 4
+
+// rasterize draws the modules of s as a black and white image, one
+// moduleSize x moduleSize block per module, surrounded by the quiet zone.
+// Palette index 1 is the dark color. Shapes, colors and logos are ignored,
+// which keeps the output legible for monochrome devices.
+func rasterize(s *Scene, moduleSize int) *image.Paletted {
+	if moduleSize < 1 {
+		moduleSize = 1
+	}
+	dim := (s.Size() + 2*s.QuietZone) * moduleSize
+	img := image.NewPaletted(image.Rect(0, 0, dim, dim), color.Palette{color.White, color.Black})
+	for y, row := range s.Modules {
+		for x, m := range row {
+			if !m.Dark {
+				continue
+			}
+			x0 := (x + s.QuietZone) * moduleSize
+			y0 := (y + s.QuietZone) * moduleSize
+			for py := y0; py < y0+moduleSize; py++ {
+				for px := x0; px < x0+moduleSize; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,469 @@
+package writer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+
+	"github.com/harogaston/go-mosaic/images"
+)
+
+const (
+	defaultLogoRelativeSize = 2. / 7.
+	defaultLogoBorderWidth  = 0.4
+	// smallest logo, in modules, worth drawing
+	minLogoSize = 5
+	// samples per module side of the logo alpha for the shape knockout,
+	// and the opacity above which a sample hides the module under it
+	knockoutSamples   = 8
+	knockoutAlpha     = 0x20
+	defaultLogoMargin = 0.5
+)
+
+// LogoPlate is the shape of the area cleared behind a logo.
+type LogoPlate string
+
+const (
+	PlateCircle  LogoPlate = "circle"
+	PlateSquare  LogoPlate = "square"
+	PlateRounded LogoPlate = "rounded"
+	// PlateNone draws the logo over the modules.
+	PlateNone LogoPlate = "none"
+	// PlateShape clears only the modules near the opaque parts of the
+	// logo image, which is drawn unclipped.
+	PlateShape LogoPlate = "shape"
+)
+
+// LogoPlacement selects where the logo goes.
+type LogoPlacement string
+
+const (
+	// PlaceCenter centers the logo, moved by LogoOptions.Offset.
+	PlaceCenter LogoPlacement = "center"
+	// PlaceAuto moves the logo to the position closest to the center, or
+	// to the offset, where it hides only data modules.
+	PlaceAuto LogoPlacement = "auto"
+)
+
+// shape returns the module shape definition that outlines the plate
+func (p LogoPlate) shape() Shape {
+	switch p {
+	case PlateCircle:
+		return ShapeCircle
+	case PlateRounded:
+		return ShapeRounded
+	default:
+		return ShapeSquare
+	}
+}
+
+// LogoOptions describes how a logo is placed over the symbol.
+type LogoOptions struct {
+	// Href is the path of a PNG, JPEG, GIF or SVG file.
+	Href string
+	// Image, when set, is drawn instead of the file at Href, for logos
+	// prepared in memory such as with the images package.
+	Image image.Image
+	// RelativeSize is the side of the logo as a fraction of the symbol
+	// side, 2/7 when zero.
+	RelativeSize float64
+	// Plate is the shape of the cleared area, which also clips the logo.
+	// When empty it follows the module shape.
+	Plate LogoPlate
+	// PlateColor fills the plate. When nil the scene background shows.
+	PlateColor color.Color
+	// BorderWidth is the width in modules of the ring drawn around the
+	// plate. Zero selects 0.4 for curved plates and no ring for square
+	// ones, a negative width disables the ring.
+	BorderWidth float64
+	// Margin is the distance in modules from the opaque parts of the logo
+	// within which PlateShape clears modules. Zero selects half a module,
+	// a negative margin clears only the modules the logo overlaps.
+	Margin float64
+	// Placement is PlaceCenter when empty.
+	Placement LogoPlacement
+	// Offset moves the logo from the center, in modules. An offset logo
+	// must hide only data modules, while a centered one may cover an
+	// alignment pattern.
+	Offset image.Point
+}
+
+// Role identifies the structural purpose of a module in the symbol.
+type Role string
+
+const (
+	RoleData       Role = "data" // data and error correction codewords, remainder bits
+	RoleFinder     Role = "finder"
+	RoleSeparator  Role = "separator"
+	RoleTiming     Role = "timing"
+	RoleAlignment  Role = "alignment"
+	RoleFormat     Role = "format"
+	RoleVersion    Role = "version"
+	RoleDarkModule Role = "dark"
+)
+
+// Module is a single cell of the symbol.
+type Module struct {
+	Dark bool
+	Role Role
+}
+
+// Logo is the placement of a logo over the symbol. Positions and sizes are
+// in modules, relative to the top left corner of the symbol.
+type Logo struct {
+	Href  string
+	Image image.Image
+	// X and Y are the column and row of the top left corner of the logo
+	// area.
+	X, Y int
+	// Size is the side of the logo area.
+	Size       int
+	Plate      LogoPlate
+	PlateColor color.Color
+	// BorderWidth is the width of the ring around the plate, zero for none.
+	BorderWidth float64
+	// Cleared marks the modules around the logo painted with the
+	// background color.
+	Cleared [][]bool
+	// Covered marks the modules under the logo image, which are unreadable
+	// whatever their color.
+	Covered [][]bool
+}
+
+// Scene is the renderer independent layout of a symbol: its modules with
+// their roles, the finder and alignment overlays, the logo and the colors.
+type Scene struct {
+	Modules [][]Module
+	// Finders holds the [x, y] top left corner of each finder pattern.
+	Finders [][]int
+	// AlignmentPatterns holds the [row, col] centers of the alignment
+	// patterns.
+	AlignmentPatterns [][]int
+	Shape             Shape
+	// EyeFrame and EyeBall shape the rings of the finder and alignment
+	// patterns, empty follows Shape.
+	EyeFrame EyeShape
+	EyeBall  EyeShape
+	// Color paints the dark modules, Background the light ones. A
+	// transparent background lets the page show through.
+	Color      color.Color
+	Background color.Color
+	// Gradient paints the dark modules instead of Color when set. Color
+	// remains for the renderers that only draw flat colors.
+	Gradient *Gradient
+	// Colors paints parts of the symbol, selected by the module roles,
+	// with colors of their own.
+	Colors ComponentColors
+	// QuietZone is the width of the light margin, in modules.
+	QuietZone int
+	// QuietZoneColor paints the margin when set, the background does
+	// otherwise. Renderers without area fills, such as HTML and the printer
+	// formats, always use the background.
+	QuietZoneColor color.Color
+	Logo           *Logo
+	// Data and Level describe the encoded content, for renderers that can
+	// delegate encoding to the output device.
+	Data  string
+	Level string
+	Debug bool
+}
+
+// Renderer writes a scene in an output format.
+type Renderer interface {
+	Render(w io.Writer, s *Scene) error
+}
+
+// NewScene lays out a symbol with black on white colors and places the
+// finder overlays.
+func NewScene(modules [][]Module, alignmentPatterns [][]int, shape Shape) *Scene {
+	if shape == "" {
+		shape = ShapeSquare
+	}
+	dim := len(modules)
+	s := &Scene{
+		Modules:           modules,
+		Finders:           [][]int{{0, 0}, {dim - 7, 0}, {0, dim - 7}},
+		AlignmentPatterns: alignmentPatterns,
+		Shape:             shape,
+		Color:             color.Black,
+		Background:        color.White,
+		QuietZone:         defaultQuietZone,
+	}
+	return s
+}
+
+// PlaceLogo places a logo over the symbol and clears the modules within
+// its plate. It leaves the scene without logo when the symbol is too small
+// to hold one, and fails when no allowed position keeps the logo clear of
+// the function patterns.
+func (s *Scene) PlaceLogo(opts LogoOptions) error {
+	logo, err := layoutLogo(s, opts)
+	if err != nil {
+		return err
+	}
+	s.Logo = logo
+	return nil
+}
+
+func layoutLogo(s *Scene, opts LogoOptions) (*Logo, error) {
+	dim := s.Size()
+	if opts.RelativeSize <= 0 {
+		opts.RelativeSize = defaultLogoRelativeSize
+	}
+	if opts.Plate == "" {
+		switch s.Shape {
+		case ShapeCircle:
+			opts.Plate = PlateCircle
+		case ShapeSquare:
+			opts.Plate = PlateSquare
+		default:
+			opts.Plate = PlateRounded
+		}
+	}
+	switch {
+	case opts.BorderWidth < 0, opts.Plate == PlateNone, opts.Plate == PlateShape:
+		opts.BorderWidth = 0
+	case opts.BorderWidth == 0 && opts.Plate != PlateSquare:
+		opts.BorderWidth = defaultLogoBorderWidth
+	}
+
+	// Ensure logo size is always odd
+	logoSize := int(math.Floor(float64(dim) * opts.RelativeSize))
+	logoSize += (logoSize + 1) % 2
+	if logoSize < minLogoSize {
+		return nil, nil
+	}
+	footprint, err := logoFootprint(s.Shape, logoSize, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	center := dim/2 - logoSize/2
+	x, y := center+opts.Offset.X, center+opts.Offset.Y
+	switch {
+	case opts.Placement == PlaceAuto:
+		var ok bool
+		if x, y, ok = footprint.nearestFree(s, x, y); !ok {
+			return nil, fmt.Errorf("no position keeps a logo of %d modules clear of the function patterns", logoSize)
+		}
+	case opts.Offset != image.Point{}:
+		if !footprint.free(s, x, y) {
+			return nil, fmt.Errorf("logo at offset %d,%d overlaps the function patterns", opts.Offset.X, opts.Offset.Y)
+		}
+	}
+
+	logo := &Logo{
+		Href:        opts.Href,
+		Image:       opts.Image,
+		X:           x,
+		Y:           y,
+		Size:        logoSize,
+		Plate:       opts.Plate,
+		PlateColor:  opts.PlateColor,
+		BorderWidth: opts.BorderWidth,
+		Cleared:     make([][]bool, dim),
+		Covered:     make([][]bool, dim),
+	}
+	for i := range dim {
+		logo.Cleared[i] = make([]bool, dim)
+		logo.Covered[i] = make([]bool, dim)
+	}
+	// The centered logo may reach past the symbol on small versions
+	inside := func(p image.Point) bool { return p.X >= 0 && p.X < dim && p.Y >= 0 && p.Y < dim }
+	for _, p := range footprint.cleared {
+		if p = p.Add(image.Pt(x, y)); inside(p) {
+			logo.Cleared[p.Y][p.X] = true
+		}
+	}
+	for _, p := range footprint.covered {
+		if p = p.Add(image.Pt(x, y)); inside(p) {
+			logo.Covered[p.Y][p.X] = true
+		}
+	}
+	return logo, nil
+}
+
+// footprint lists the modules a logo hides, relative to its top left
+// corner
+type footprint struct {
+	// cleared modules are painted with the background color
+	cleared []image.Point
+	// covered modules lie under the logo image
+	covered []image.Point
+}
+
+// logoFootprint returns the modules hidden by a logo of size modules. Plates
+// clear the modules whose center falls within them, the shape knockout the
+// modules within the margin of an opaque part of the image.
+func logoFootprint(shape Shape, size int, opts LogoOptions) (footprint, error) {
+	var f footprint
+	if opts.Plate == PlateShape {
+		return shapeFootprint(size, opts)
+	}
+	var padding float64
+	if shape != ShapeSquare {
+		padding = 2.0
+	} else {
+		padding = 1.0
+	}
+	// Create safe zone around logo
+	half := float64(size)/2. + padding
+	for y := -1; y < size+1; y++ {
+		for x := -1; x < size+1; x++ {
+			dx := float64(x) + .5 - float64(size)/2.
+			dy := float64(y) + .5 - float64(size)/2.
+			if insidePlate(opts.Plate, dx, dy, half) {
+				f.cleared = append(f.cleared, image.Pt(x, y))
+			}
+			if x >= 0 && x < size && y >= 0 && y < size {
+				f.covered = append(f.covered, image.Pt(x, y))
+			}
+		}
+	}
+	return f, nil
+}
+
+// shapeFootprint clears the modules within opts.Margin of the opaque
+// samples of the logo image, fitted into its square as the renderers draw
+// it. SVG logos are not rasterized, their whole square counts as opaque.
+func shapeFootprint(size int, opts LogoOptions) (footprint, error) {
+	margin := opts.Margin
+	switch {
+	case margin == 0:
+		margin = defaultLogoMargin
+	case margin < 0:
+		margin = 0
+	}
+	side := size * knockoutSamples
+	var coverage *image.Alpha
+	if opts.Image == nil && IsSVGLogo(opts.Href) {
+		coverage = image.NewAlpha(image.Rect(0, 0, side, side))
+		for i := range coverage.Pix {
+			coverage.Pix[i] = 0xff
+		}
+	} else {
+		img, err := logoImage(opts.Href, opts.Image)
+		if err != nil {
+			return footprint{}, err
+		}
+		// Sample the logo where the renderers draw it, fitted into its
+		// square
+		coverage = image.NewAlpha(image.Rect(0, 0, side, side))
+		x, y, w, h := fitLogo(img.Bounds(), 0, 0, float64(side))
+		fitted := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+		draw.Draw(coverage, fitted, images.Coverage(img, fitted.Dx(), fitted.Dy()), image.Point{}, draw.Src)
+	}
+
+	reach := int(math.Ceil(margin))
+	span := size + 2*reach
+	cleared := make([]bool, span*span)
+	covered := make([]bool, size*size)
+	for sy := range side {
+		for sx := range side {
+			if coverage.AlphaAt(sx, sy).A < knockoutAlpha {
+				continue
+			}
+			// Sample center in modules from the top left corner of the logo
+			px := (float64(sx) + .5) / knockoutSamples
+			py := (float64(sy) + .5) / knockoutSamples
+			covered[int(py)*size+int(px)] = true
+			for y := int(py) - reach; y <= int(py)+reach; y++ {
+				for x := int(px) - reach; x <= int(px)+reach; x++ {
+					// distance from the sample to the module square
+					dx := max(float64(x)-px, px-float64(x+1), 0)
+					dy := max(float64(y)-py, py-float64(y+1), 0)
+					if dx*dx+dy*dy <= margin*margin {
+						cleared[(y+reach)*span+x+reach] = true
+					}
+				}
+			}
+		}
+	}
+
+	var f footprint
+	for i, c := range cleared {
+		if c {
+			f.cleared = append(f.cleared, image.Pt(i%span-reach, i/span-reach))
+		}
+	}
+	for i, c := range covered {
+		if c {
+			f.covered = append(f.covered, image.Pt(i%size, i/size))
+		}
+	}
+	return f, nil
+}
+
+// free reports whether the footprint at (x, y) stays within the symbol and
+// hides only data modules
+func (f footprint) free(s *Scene, x, y int) bool {
+	dim := s.Size()
+	for _, points := range [][]image.Point{f.cleared, f.covered} {
+		for _, p := range points {
+			p = p.Add(image.Pt(x, y))
+			if p.X < 0 || p.X >= dim || p.Y < 0 || p.Y >= dim || s.Modules[p.Y][p.X].Role != RoleData {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// nearestFree returns the free position closest to (x, y), scanning rows
+// from the top on ties
+func (f footprint) nearestFree(s *Scene, x, y int) (int, int, bool) {
+	best, bestX, bestY := -1, 0, 0
+	for cy := range s.Size() {
+		for cx := range s.Size() {
+			d := (cx-x)*(cx-x) + (cy-y)*(cy-y)
+			if (best < 0 || d < best) && f.free(s, cx, cy) {
+				best, bestX, bestY = d, cx, cy
+			}
+		}
+	}
+	return bestX, bestY, best >= 0
+}
+
+// insidePlate reports whether the point at (dx, dy) from the plate center
+// lies within a plate of half side half
+func insidePlate(plate LogoPlate, dx, dy, half float64) bool {
+	switch plate {
+	case PlateCircle:
+		return dx*dx+dy*dy < half*half
+	case PlateSquare:
+		return math.Abs(dx) < half && math.Abs(dy) < half
+	case PlateRounded:
+		// same corner ratio as the rounded module
+		r := 0.35 * 2 * half
+		qx := max(math.Abs(dx)-(half-r), 0)
+		qy := max(math.Abs(dy)-(half-r), 0)
+		return math.Abs(dx) < half && math.Abs(dy) < half && qx*qx+qy*qy < r*r
+	}
+	return false
+}
+
+// Size returns the number of modules per side, without the quiet zone.
+func (s *Scene) Size() int {
+	return len(s.Modules)
+}
+
+// Dark reports whether the module at column x and row y is dark. Positions
+// outside the symbol are light.
+func (s *Scene) Dark(x, y int) bool {
+	return y >= 0 && y < len(s.Modules) && x >= 0 && x < len(s.Modules[y]) && s.Modules[y][x].Dark
+}
+
+// IsOverlay reports whether the module is drawn by a finder or alignment
+// overlay instead of individually.
+func (s *Scene) IsOverlay(x, y int) bool {
+	role := s.Modules[y][x].Role
+	return role == RoleFinder || role == RoleAlignment
+}
+
+// Cleared reports whether the module is hidden by the logo clearance.
+func (s *Scene) Cleared(x, y int) bool {
+	return s.Logo != nil && s.Logo.Cleared[y][x]
+}
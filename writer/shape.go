@@ -1,5 +1,7 @@
 package writer
 
+import "math"
+
 type Shape string
 
 const (
@@ -8,4 +10,72 @@ const (
 	ShapeRounded  Shape = "rounded"
 	ShapeSlanted  Shape = "slanted"
 	ShapeSquircle Shape = "squircle"
+	// ShapeConnected merges neighboring modules into bars and blocks, with
+	// only their outer corners rounded.
+	ShapeConnected Shape = "connected"
+	// ShapeLiquid rounds the inner corners of ShapeConnected too and joins
+	// diagonal neighbors.
+	ShapeLiquid Shape = "liquid"
+	// ShapeHorizontal and ShapeVertical draw the modules as rounded lines
+	// along the rows or the columns.
+	ShapeHorizontal Shape = "horizontal"
+	ShapeVertical   Shape = "vertical"
 )
+
+// connected reports whether the outline of a module of shape depends on
+// its neighbors
+func (shape Shape) connected() bool {
+	switch shape {
+	case ShapeConnected, ShapeLiquid, ShapeHorizontal, ShapeVertical:
+		return true
+	}
+	return false
+}
+
+// lines reports whether shape draws the modules as lines
+func (shape Shape) lines() bool {
+	return shape == ShapeHorizontal || shape == ShapeVertical
+}
+
+// ShapeOutline returns a convex polygon approximating shape inside the unit
+// module square, with y pointing down. segments is the number of vertices
+// used for a full circle; corners of rounded shapes get a quarter of them.
+func ShapeOutline(shape Shape, segments int) [][2]float64 {
+	if segments < 4 {
+		segments = 4
+	}
+	var outline [][2]float64
+	switch shape {
+	case ShapeCircle:
+		for i := range segments {
+			a := 2 * math.Pi * float64(i) / float64(segments)
+			outline = append(outline, [2]float64{0.5 + 0.5*math.Cos(a), 0.5 + 0.5*math.Sin(a)})
+		}
+	case ShapeRounded:
+		// same radius as the rounded SVG definition
+		const r = 0.35
+		corners := [][2]float64{{1 - r, 1 - r}, {r, 1 - r}, {r, r}, {1 - r, r}}
+		perCorner := segments / 4
+		for c, center := range corners {
+			for i := range perCorner + 1 {
+				a := math.Pi/2*float64(c) + math.Pi/2*float64(i)/float64(perCorner)
+				outline = append(outline, [2]float64{center[0] + r*math.Cos(a), center[1] + r*math.Sin(a)})
+			}
+		}
+	case ShapeSquircle:
+		// superellipse |x|^4 + |y|^4 = 1
+		for i := range segments {
+			a := 2 * math.Pi * float64(i) / float64(segments)
+			c, s := math.Cos(a), math.Sin(a)
+			outline = append(outline, [2]float64{
+				0.5 + 0.5*math.Copysign(math.Sqrt(math.Abs(c)), c),
+				0.5 + 0.5*math.Copysign(math.Sqrt(math.Abs(s)), s),
+			})
+		}
+	case ShapeSlanted:
+		outline = [][2]float64{{0.25, 0}, {1, 0}, {0.75, 1}, {0, 1}}
+	default:
+		outline = [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	}
+	return outline
+}
@@ -0,0 +1,202 @@
+package writer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+const (
+	defaultBaseThickness = 2.0
+	defaultReliefHeight  = 1.0
+	// polygon vertices used to approximate a full circle
+	defaultCurveSegments = 24
+)
+
+// STLRenderer writes a printable plate with the dark modules raised above
+// it. Lengths are in millimetres. The logo is not part of the mesh, the
+// modules it clears are left flat so it can be painted or glued on.
+type STLRenderer struct {
+	// ModuleSize is the side of a module.
+	ModuleSize float64
+	// BaseThickness is the height of the plate, which covers the symbol
+	// and its quiet zone.
+	BaseThickness float64
+	// ReliefHeight is how far the dark modules rise above the plate.
+	ReliefHeight float64
+	// Segments is the number of polygon vertices used to approximate a
+	// full circle in curved shapes.
+	Segments int
+	// ASCII selects the text STL format instead of binary.
+	ASCII bool
+}
+
+type stlTriangle [3][3]float64
+
+func (t stlTriangle) normal() [3]float64 {
+	u := [3]float64{t[1][0] - t[0][0], t[1][1] - t[0][1], t[1][2] - t[0][2]}
+	v := [3]float64{t[2][0] - t[0][0], t[2][1] - t[0][1], t[2][2] - t[0][2]}
+	n := [3]float64{u[1]*v[2] - u[2]*v[1], u[2]*v[0] - u[0]*v[2], u[0]*v[1] - u[1]*v[0]}
+	length := math.Sqrt(n[0]*n[0] + n[1]*n[1] + n[2]*n[2])
+	if length == 0 {
+		return n
+	}
+	return [3]float64{n[0] / length, n[1] / length, n[2] / length}
+}
+
+// Render writes a mesh made of a base plate and one extruded solid per dark
+// module (or per horizontal run of dark modules for the square shape). The
+// solids overlap the plate faces, which slicers merge.
+func (r STLRenderer) Render(w io.Writer, s *Scene) error {
+	if r.ModuleSize <= 0 {
+		r.ModuleSize = defaultModuleMM
+	}
+	if r.BaseThickness <= 0 {
+		r.BaseThickness = defaultBaseThickness
+	}
+	if r.ReliefHeight <= 0 {
+		r.ReliefHeight = defaultReliefHeight
+	}
+	if r.Segments < 4 {
+		r.Segments = defaultCurveSegments
+	}
+
+	total := float64(s.Size()+2*s.QuietZone) * r.ModuleSize
+	var triangles []stlTriangle
+
+	// Base plate
+	triangles = appendPrism(triangles, [][2]float64{{0, 0}, {total, 0}, {total, total}, {0, total}}, 0, r.BaseThickness)
+
+	top := r.BaseThickness + r.ReliefHeight
+	// place converts a unit outline of the module at (x, y) to millimetres
+	// with the y axis pointing up
+	place := func(outline [][2]float64, x, y float64) [][2]float64 {
+		placed := make([][2]float64, len(outline))
+		for i, p := range outline {
+			placed[i] = [2]float64{
+				(x + float64(s.QuietZone) + p[0]) * r.ModuleSize,
+				total - (y+float64(s.QuietZone)+p[1])*r.ModuleSize,
+			}
+		}
+		return placed
+	}
+
+	if s.Shape == ShapeSquare {
+		for y, row := range s.Modules {
+			if s.Logo != nil {
+				row = slices.Clone(row)
+				for x := range row {
+					row[x].Dark = row[x].Dark && !s.Cleared(x, y)
+				}
+			}
+			for _, run := range darkRuns(row) {
+				width := float64(run[1] - run[0])
+				outline := [][2]float64{{0, 0}, {width, 0}, {width, 1}, {0, 1}}
+				triangles = appendPrism(triangles, place(outline, float64(run[0]), float64(y)), r.BaseThickness, top)
+			}
+		}
+	} else if s.Shape.connected() {
+		// The relief has a single color, the pieces of every color are
+		// raised alike
+		for _, fill := range s.moduleFills(true) {
+			for _, part := range s.connectedParts(fill, false) {
+				triangles = appendPrism(triangles, place(part.outline(0, 0), 0, 0), r.BaseThickness, top)
+			}
+		}
+	} else {
+		outline := ShapeOutline(s.Shape, r.Segments)
+		for y, row := range s.Modules {
+			for x, m := range row {
+				if m.Dark && !s.Cleared(x, y) {
+					triangles = appendPrism(triangles, place(outline, float64(x), float64(y)), r.BaseThickness, top)
+				}
+			}
+		}
+	}
+
+	if r.ASCII {
+		return writeSTLText(w, triangles)
+	}
+	return writeSTLBinary(w, triangles)
+}
+
+// appendPrism extrudes a polygon between heights z0 and z1. The caps are
+// fanned out from the first vertex, which must see every other one, as in
+// convex polygons and the fillets of ShapeLiquid.
+func appendPrism(triangles []stlTriangle, polygon [][2]float64, z0, z1 float64) []stlTriangle {
+	// Facets must be counterclockwise seen from outside, so the polygon is
+	// made counterclockwise seen from above, keeping its first vertex
+	area := 0.
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		area += p[0]*q[1] - q[0]*p[1]
+	}
+	if area < 0 {
+		reversed := make([][2]float64, len(polygon))
+		reversed[0] = polygon[0]
+		for i, p := range polygon[1:] {
+			reversed[len(polygon)-1-i] = p
+		}
+		polygon = reversed
+	}
+
+	at := func(p [2]float64, z float64) [3]float64 { return [3]float64{p[0], p[1], z} }
+	for i := 1; i < len(polygon)-1; i++ {
+		triangles = append(triangles,
+			stlTriangle{at(polygon[0], z1), at(polygon[i], z1), at(polygon[i+1], z1)},
+			stlTriangle{at(polygon[0], z0), at(polygon[i+1], z0), at(polygon[i], z0)},
+		)
+	}
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		triangles = append(triangles,
+			stlTriangle{at(p, z0), at(q, z0), at(q, z1)},
+			stlTriangle{at(p, z0), at(q, z1), at(p, z1)},
+		)
+	}
+	return triangles
+}
+
+func writeSTLText(w io.Writer, triangles []stlTriangle) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("solid qr\n")
+	for _, t := range triangles {
+		n := t.normal()
+		fmt.Fprintf(bw, "  facet normal %g %g %g\n    outer loop\n", n[0], n[1], n[2])
+		for _, v := range t {
+			fmt.Fprintf(bw, "      vertex %g %g %g\n", v[0], v[1], v[2])
+		}
+		bw.WriteString("    endloop\n  endfacet\n")
+	}
+	bw.WriteString("endsolid qr\n")
+	return bw.Flush()
+}
+
+func writeSTLBinary(w io.Writer, triangles []stlTriangle) error {
+	bw := bufio.NewWriter(w)
+	var header [80]byte
+	copy(header[:], "go-mosaic QR code")
+	bw.Write(header[:])
+	binary.Write(bw, binary.LittleEndian, uint32(len(triangles)))
+
+	// normal, 3 vertices and a 2 byte attribute count per facet
+	var facet [50]byte
+	put := func(offset int, v [3]float64) {
+		for i, f := range v {
+			binary.LittleEndian.PutUint32(facet[offset+4*i:], math.Float32bits(float32(f)))
+		}
+	}
+	for _, t := range triangles {
+		put(0, t.normal())
+		put(12, t[0])
+		put(24, t[1])
+		put(36, t[2])
+		if _, err := bw.Write(facet[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,176 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// parseSTLBinary reads the facets of a binary STL document
+func parseSTLBinary(t *testing.T, data []byte) []stlTriangle {
+	t.Helper()
+	if len(data) < 84 {
+		t.Fatalf("STL of %d bytes", len(data))
+	}
+	n := int(binary.LittleEndian.Uint32(data[80:]))
+	if len(data) != 84+50*n {
+		t.Fatalf("STL of %d bytes for %d facets", len(data), n)
+	}
+	triangles := make([]stlTriangle, n)
+	for i := range triangles {
+		facet := data[84+50*i:]
+		for v := range 3 {
+			for c := range 3 {
+				bits := binary.LittleEndian.Uint32(facet[12+12*v+4*c:])
+				triangles[i][v][c] = float64(math.Float32frombits(bits))
+			}
+		}
+	}
+	return triangles
+}
+
+// capArea returns the area of the facets at height z, positive when they
+// face up
+func capArea(triangles []stlTriangle, z float64) float64 {
+	area := 0.
+	for _, t := range triangles {
+		if t[0][2] != z || t[1][2] != z || t[2][2] != z {
+			continue
+		}
+		area += ((t[1][0]-t[0][0])*(t[2][1]-t[0][1]) - (t[2][0]-t[0][0])*(t[1][1]-t[0][1])) / 2
+	}
+	return area
+}
+
+func TestSTLLiquidCaps(t *testing.T) {
+	s := sceneFromRows(
+		"##..",
+		"..#.",
+		"....",
+		"....",
+	)
+	s.Shape = ShapeLiquid
+	s.QuietZone = 0
+	var buf bytes.Buffer
+	if err := (STLRenderer{ModuleSize: 1, BaseThickness: 1, ReliefHeight: 1}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	triangles := parseSTLBinary(t, buf.Bytes())
+	// Three modules lose six rounded corners and the diagonal join gains
+	// two fillets, each the size of a rounded corner
+	corner := 0.25 - math.Pi/16
+	want := 3 - 4*corner
+	if area := capArea(triangles, 2); math.Abs(area-want) > 0.01 {
+		t.Errorf("relief caps cover %g, want %g", area, want)
+	}
+	for _, tri := range triangles {
+		if n := tri.normal(); tri[0][2] == 2 && tri[1][2] == 2 && tri[2][2] == 2 && n[2] <= 0 {
+			t.Errorf("top facet %v faces down", tri)
+		}
+	}
+}
+
+func TestSTLBinary(t *testing.T) {
+	s := sceneFromRows("#.", "##")
+	s.QuietZone = 0
+	var buf bytes.Buffer
+	if err := (STLRenderer{}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("go-mosaic QR code\x00")) {
+		t.Errorf("header %q", data[:80])
+	}
+	// 12 facets for the plate and for each of the two runs
+	if n := len(parseSTLBinary(t, data)); n != 36 {
+		t.Errorf("%d facets, want 36", n)
+	}
+}
+
+func TestSTLASCII(t *testing.T) {
+	s := sceneFromRows("#.", "##")
+	s.QuietZone = 0
+	s.Shape = ShapeCircle
+	var binaryBuf, text bytes.Buffer
+	if err := (STLRenderer{Segments: 8}).Render(&binaryBuf, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := (STLRenderer{Segments: 8, ASCII: true}).Render(&text, s); err != nil {
+		t.Fatal(err)
+	}
+	triangles := parseSTLBinary(t, binaryBuf.Bytes())
+
+	out := text.String()
+	if !strings.HasPrefix(out, "solid qr\n") || !strings.HasSuffix(out, "endsolid qr\n") {
+		t.Errorf("no solid qr ... endsolid qr in\n%s", out)
+	}
+	var vertices [][3]float64
+	for _, line := range strings.Split(out, "\n") {
+		var v [3]float64
+		if _, err := fmt.Sscanf(strings.TrimSpace(line), "vertex %g %g %g", &v[0], &v[1], &v[2]); err == nil {
+			vertices = append(vertices, v)
+		}
+	}
+	if len(vertices) != 3*len(triangles) || strings.Count(out, "facet normal") != len(triangles) {
+		t.Fatalf("%d vertices, want the %d facets of the binary STL", len(vertices), len(triangles))
+	}
+	for i, v := range vertices {
+		want := triangles[i/3][i%3]
+		for c := range 3 {
+			if math.Abs(v[c]-want[c]) > 1e-4 {
+				t.Fatalf("vertex %d is %v, want %v", i, v, want)
+			}
+		}
+	}
+}
+
+func TestSTLHeights(t *testing.T) {
+	s := sceneFromRows("#.", "##")
+	s.QuietZone = 1
+	var buf bytes.Buffer
+	if err := (STLRenderer{ModuleSize: 2, BaseThickness: 3, ReliefHeight: 0.5}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	triangles := parseSTLBinary(t, buf.Bytes())
+	heights := map[float64]bool{}
+	for _, tri := range triangles {
+		for _, v := range tri {
+			heights[v[2]] = true
+			if v[0] < 0 || v[0] > 8 || v[1] < 0 || v[1] > 8 {
+				t.Fatalf("vertex %v outside the 8 mm plate", v)
+			}
+		}
+	}
+	if len(heights) != 3 || !heights[0] || !heights[3] || !heights[3.5] {
+		t.Errorf("heights %v, want 0, 3 and 3.5", heights)
+	}
+	if area := capArea(triangles, 0); area != -64 {
+		t.Errorf("plate bottom covers %g, want 64 facing down", area)
+	}
+	if area := capArea(triangles, 3.5); area != 12 {
+		t.Errorf("relief top covers %g, want 12", area)
+	}
+}
+
+func TestSTLClearedModules(t *testing.T) {
+	// Cleared modules are left flat, as if they were light
+	for _, shape := range []Shape{ShapeSquare, ShapeCircle, ShapeConnected} {
+		var meshes [2]bytes.Buffer
+		for i, s := range []*Scene{sceneFromRows("###", "###", "###"), sceneFromRows("###", "#..", "###")} {
+			s.QuietZone = 0
+			s.Shape = shape
+			if i == 0 {
+				s.Logo = &Logo{Cleared: [][]bool{{false, false, false}, {false, true, true}, {false, false, false}}}
+			}
+			if err := (STLRenderer{}).Render(&meshes[i], s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(meshes[0].Bytes(), meshes[1].Bytes()) {
+			t.Errorf("%s: modules under the logo are raised", shape)
+		}
+	}
+}
@@ -1,30 +1,53 @@
 package writer
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"image/color"
+	"io"
 	"math"
-	"os"
+	"slices"
+	"strconv"
+	"strings"
 
 	svg "github.com/twpayne/go-svg"
 	"github.com/twpayne/go-svg/svgpath"
 )
 
 const (
-	output_file_path string = "qr.svg"
-	logoRelativeSize        = 2. / 7.
-	cell_gap                = 0.125
-	logoBorderWidth         = 0.4
+	cell_gap            = 0.125
+	defaultModulePixels = 16
+	// decimals of the numbers written in compact mode
+	defaultSVGPrecision = 3
 )
 
-type SVGRequest struct {
-	Scale             int
-	Cells             [][]color.Color
-	AlignmentPatterns [][]int
-	Shape             Shape
-	Logo              string
-	Color             color.Color
-	Debug             bool
+// SVGRenderer draws every module as a reference to a shape definition and
+// superimposes the finder and alignment patterns.
+type SVGRenderer struct {
+	// Scale is the number of pixels per module of the rendered document.
+	Scale int
+	// Width and Height set the size of the document in pixels instead of
+	// Scale. When only one is set the document is square.
+	Width, Height int
+	// Compact traces the dark modules into a single path for the square
+	// shape, and styles the modules of other shapes with a shared class
+	// instead of an inline style. The document is not indented.
+	Compact bool
+	// Precision is the number of decimals of the numbers written in
+	// compact mode, defaultSVGPrecision when zero.
+	Precision int
+	// IDPrefix is prepended to every id and class name so several codes can
+	// be inlined in one HTML page. When empty it is derived from a hash of
+	// the encoded data.
+	IDPrefix string
+	// Sprite wraps the drawing in a <symbol> with the id <IDPrefix>code
+	// inside a zero sized document. Pages containing many codes include the
+	// sprites once and draw them with <use href="#<IDPrefix>code">.
+	Sprite bool
+	// LinkLogo references raster logos by their path instead of embedding
+	// them as data URIs, which keeps the document small but not portable.
+	LinkLogo bool
 }
 
 // GenerateRoundedSquare returns an SVG path string for a 1x1 square
@@ -76,164 +99,404 @@ func GenerateSquircle(curviness float64) *svgpath.Path {
 		ClosePath()
 }
 
-func WriteSVG(req SVGRequest) {
-	if req.Color == nil {
-		req.Color = color.Black
+// Render writes the scene as an SVG document.
+func (r SVGRenderer) Render(w io.Writer, s *Scene) error {
+	if r.Scale < 1 {
+		r.Scale = defaultModulePixels
 	}
+	if r.Precision <= 0 {
+		r.Precision = defaultSVGPrecision
+	}
+	bg := s.Background
 
-	file, err := os.Create(output_file_path)
-	if err != nil {
-		fmt.Println("Error creating SVG file:", err)
+	num := fixedNumber
+	if r.Compact {
+		num = func(v float64) string { return roundedNumber(v, r.Precision) }
+	}
+	transform := func(shape Shape, scale, pos, padding float64) string {
+		return shapeTransform(shape, scale, pos, padding, num)
+	}
+	transformXY := func(shape Shape, scale, x, y float64) string {
+		return shapeTransformXY(shape, scale, x, y, 0, num)
+	}
+	style := func(shape Shape, target, background, source color.Color, scale float64) string {
+		return shapeStyle(shape, target, background, source, scale, num)
+	}
+	prefix := r.IDPrefix
+	if prefix == "" {
+		prefix = autoIDPrefix(s)
+	}
+	id := func(name string) string { return prefix + name }
+	shapeRef := svg.String("#" + id(string(s.Shape)))
+	// The merged path of the compact square shape, and of the connected
+	// shapes, already draws the finder and alignment patterns, unless the
+	// eyes have shapes of their own
+	overlays := !r.Compact || s.Shape != ShapeSquare || s.customEyes()
+	if s.Shape.connected() {
+		overlays = s.customEyes()
 	}
-	defer file.Close()
 
-	dim := len(req.Cells)
-	quietZone := 4
+	dim := s.Size()
+	// One user unit per module, with the origin at the top left module so
+	// the quiet zone has negative coordinates
+	qz := float64(s.QuietZone)
+	total := float64(dim) + 2*qz
+	// 0 - qz rather than -qz, which writes -0 without a quiet zone
+	origin := 0 - qz
+	width, height := r.Width, r.Height
+	switch {
+	case width <= 0 && height <= 0:
+		width = int(total) * r.Scale
+		height = width
+	case width <= 0:
+		width = height
+	case height <= 0:
+		height = width
+	}
 	canvas := svg.New().
-		WidthHeight(float64(dim), float64(dim), svg.Number).
-		Transform(svg.String(fmt.Sprintf("scale(%d) translate(%d, %d)", req.Scale, quietZone, quietZone)))
-	canvas.Attrs["transform-origin"] = svg.String("0 0")
+		WidthHeight(float64(width), float64(height), svg.Number).
+		ViewBox(origin, origin, total, total)
 
 	// Definitions
-	circle := svg.Circle().R(svg.Number(0.5)).ID(svg.String(ShapeCircle))
+	circle := svg.Circle().R(svg.Number(0.5)).ID(svg.String(id(string(ShapeCircle))))
 	circle.Attrs["transform"] = svg.String("translate(0.5,0.5)")
-	square := svg.Rect().XYWidthHeight(0, 0, 1, 1, svg.Number).ID(svg.String(ShapeSquare))
-	rounded := svg.Path().D(GenerateRoundedSquare(0.35)).ID(svg.String(ShapeRounded))
-	squircle := svg.Path().D(GenerateSquircle(0.125)).ID(svg.String(ShapeSquircle))
-
-	alignmentBackground := svg.Use().Href(svg.String("#square")).Style("fill:white")
-	alignmentBackground.Attrs["transform"] = svg.String(GetTransform(ShapeSquare, 5.0, 0.0, 0.))
-	alignmentOuterRing := svg.Use().Href(svg.String("#" + string(req.Shape))).Style(svg.String(GetStyle(req.Shape, req.Color, color.Black, 5.0)))
-	alignmentOuterRing.Attrs["transform"] = svg.String(GetTransform(req.Shape, 5.0, 0.0, 0.2))
-	alignmentMiddleRing := svg.Use().Href(svg.String("#" + string(req.Shape))).Style(svg.String(NoStrokeStyle(color.White, color.White)))
-	alignmentMiddleRing.Attrs["transform"] = svg.String(GetTransform(req.Shape, 3.0, 1.0, 0.))
-	alignmentCenterRing := svg.Use().Href(svg.String("#" + string(req.Shape))).Style(svg.String(NoStrokeStyle(req.Color, color.Black)))
-	alignmentCenterRing.Attrs["transform"] = svg.String(GetTransform(req.Shape, 1.0, 2.0, 0.))
-	alignmentPatternGroup := svg.G(alignmentBackground, alignmentOuterRing, alignmentMiddleRing, alignmentCenterRing).ID("alignmentpattern")
-
-	finderBackground := svg.Use().Href(svg.String("#square")).Style("fill:white")
-	finderBackground.Attrs["transform"] = svg.String(GetTransform(ShapeSquare, 7.0, 0.0, 0.))
-	finderOuterRing := svg.Use().Href(svg.String("#" + string(req.Shape))).Style(svg.String(GetStyle(req.Shape, req.Color, color.Black, 7.)))
-	finderOuterRing.Attrs["transform"] = svg.String(GetTransform(req.Shape, 7.0, 0.0, 0.2))
-	finderMiddleRing := svg.Use().Href(svg.String("#" + string(req.Shape))).Style(svg.String(NoStrokeStyle(color.White, color.White)))
-	finderMiddleRing.Attrs["transform"] = svg.String(GetTransform(req.Shape, 5.0, 1.0, 0.))
-	finderCenterRing := svg.Use().Href(svg.String("#" + string(req.Shape))).Style(svg.String(NoStrokeStyle(req.Color, color.Black)))
-	finderCenterRing.Attrs["transform"] = svg.String(GetTransform(req.Shape, 3.0, 2.0, 0.))
-	finderPatternGroup := svg.G(finderBackground, finderOuterRing, finderMiddleRing, finderCenterRing).ID("finderpattern")
-
-	canvas.AppendChildren(
-		svg.Defs(
-			circle,
-			square,
-			rounded,
-			squircle,
-			finderPatternGroup,
-			alignmentPatternGroup,
-		),
-	)
+	square := svg.Rect().XYWidthHeight(0, 0, 1, 1, svg.Number).ID(svg.String(id(string(ShapeSquare))))
+	rounded := svg.Path().D(GenerateRoundedSquare(0.35)).ID(svg.String(id(string(ShapeRounded))))
+	squircle := svg.Path().D(GenerateSquircle(0.125)).ID(svg.String(id(string(ShapeSquircle))))
+
+	// The modules the gradient paints are drawn white on black into a mask
+	// over a rect filled with it. A gradient in user space would restart in
+	// every module drawn with <use>.
+	gradient := s.Gradient
+	var inked []svg.Element
+	// colors returns the color of modules painted with fill and of the gap
+	// around them
+	colors := func(fill color.Color) (color.Color, color.Color) {
+		if fill == nil {
+			return color.White, color.Black
+		}
+		return fill, bg
+	}
+	ink := func(fill color.Color, elements ...svg.Element) {
+		if fill == nil {
+			inked = append(inked, elements...)
+		} else {
+			canvas.AppendChildren(elements...)
+		}
+	}
 
-	// Draw Modules
-	for y, row := range req.Cells {
-		for x, c := range row {
-			if c == color.Black {
-				canvas.AppendChildren(
-					svg.Use().XY(float64(x), float64(y), svg.Number).Href(svg.String(fmt.Sprintf("#%s", req.Shape))).Style(
-						svg.String(GetStyle(req.Shape, req.Color, c, 1.0)),
-					),
-				)
+	// Eye shapes are paths in the definitions, drawn for the top left
+	// pattern and mirrored for the others.
+	eyeFrame, eyeBall := s.eyes()
+	eyeRef := func(part string, size int) svg.String {
+		return svg.String("#" + id(fmt.Sprintf("%s%d", part, size)))
+	}
+	var eyeDefs []svg.Element
+	for _, size := range []int{7, 5} {
+		if eyeFrame != "" {
+			eyeDefs = append(eyeDefs, svg.Path().D(svg.String(eyePath(eyeFrame, size, true, num))).
+				Style(svg.String("fill-rule:evenodd")).ID(svg.String(id(fmt.Sprintf("eyeframe%d", size)))))
+		}
+		if eyeBall != "" {
+			eyeDefs = append(eyeDefs, svg.Path().D(svg.String(eyePath(eyeBall, size-4, false, num))).
+				ID(svg.String(id(fmt.Sprintf("eyeball%d", size-4)))))
+		}
+	}
+
+	// Rings of 5 and 1 modules for alignment patterns, 7 and 3 for
+	// finders. A mask cuts the hole of the outer ring so the background
+	// shows through, even when it is transparent. A pattern whose parts
+	// are split between flat colors and the gradient has a copy in the
+	// gradient mask, each copy leaving out the parts of the other.
+	pattern := func(name string, size float64, outer, center color.Color, mask bool) svg.Element {
+		part := func(fill color.Color) (color.Color, color.Color) {
+			if mask != (fill == nil) {
+				return nil, nil
+			}
+			return colors(fill)
+		}
+		var frame, ball svg.Element
+		if eyeFrame != "" {
+			fg, _ := part(outer)
+			frame = svg.Use().Href(eyeRef("eyeframe", int(size))).Style(svg.String(Paint("fill", fg)))
+		}
+		if eyeBall != "" {
+			fg, _ := part(center)
+			use := svg.Use().Href(eyeRef("eyeball", int(size)-4)).Style(svg.String(Paint("fill", fg)))
+			use.Attrs["transform"] = svg.String("translate(2 2)")
+			ball = use
+		}
+		if frame != nil && ball != nil {
+			return svg.G(frame, ball).ID(svg.String(id(name)))
+		}
+		kept := svg.Use().Href(svg.String("#" + id("square"))).Style(svg.String("fill:white"))
+		kept.Attrs["transform"] = svg.String(transform(ShapeSquare, size, 0.0, 0.))
+		cut := svg.Use().Href(shapeRef).Style(svg.String("fill:black"))
+		cut.Attrs["transform"] = svg.String(transform(s.Shape, size-2, 1.0, 0.))
+		hole := svg.Mask(kept, cut).ID(svg.String(id(name + "hole")))
+		fg, gap := part(outer)
+		outerRing := svg.Use().Href(shapeRef).Style(svg.String(style(s.Shape, fg, gap, color.Black, size)))
+		outerRing.Attrs["transform"] = svg.String(transform(s.Shape, size, 0.0, 0.2))
+		fg, gap = part(center)
+		centerRing := svg.Use().Href(shapeRef).Style(svg.String(NoStrokeStyle(fg, gap, color.Black)))
+		centerRing.Attrs["transform"] = svg.String(transform(s.Shape, size-4, 2.0, 0.))
+		if frame != nil {
+			return svg.G(frame, centerRing).ID(svg.String(id(name)))
+		}
+		if ball != nil {
+			return svg.G(hole, svg.G(outerRing).Mask(svg.String("url(#"+id(name+"hole")+")")), ball).ID(svg.String(id(name)))
+		}
+		return svg.G(hole, svg.G(outerRing).Mask(svg.String("url(#"+id(name+"hole")+")")), centerRing).ID(svg.String(id(name)))
+	}
+	type overlayPattern struct {
+		name          string
+		size          float64
+		outer, center color.Color
+	}
+	alignmentPattern := overlayPattern{"alignmentpattern", 5, s.fill(ComponentAlignment), s.fill(ComponentAlignment)}
+	finderPattern := overlayPattern{"finderpattern", 7, s.fill(ComponentFinderOuter), s.fill(ComponentFinderCenter)}
+	patternDefs := eyeDefs
+	for _, p := range []overlayPattern{finderPattern, alignmentPattern} {
+		if p.outer != nil || p.center != nil {
+			patternDefs = append(patternDefs, pattern(p.name, p.size, p.outer, p.center, false))
+		}
+		if p.outer == nil || p.center == nil {
+			patternDefs = append(patternDefs, pattern(p.name+"ink", p.size, p.outer, p.center, true))
+		}
+	}
+	// overlay draws the pattern with its top left corner at (x, y), eye
+	// shapes mirrored so they face the middle of the symbol
+	overlay := func(p overlayPattern, x, y int) {
+		place := func(name string) svg.Element {
+			use := svg.Use().Href(svg.String("#" + id(name)))
+			flipX, flipY := s.eyeFlip(x, y, int(p.size))
+			if !s.customEyes() || !flipX && !flipY {
+				return use.XY(float64(x), float64(y), svg.Number)
+			}
+			tx, ty, sx, sy := float64(x), float64(y), 1., 1.
+			if flipX {
+				tx, sx = tx+p.size, -1
 			}
+			if flipY {
+				ty, sy = ty+p.size, -1
+			}
+			use.Attrs["transform"] = svg.String(fmt.Sprintf("translate(%s %s) scale(%g %g)", num(tx), num(ty), sx, sy))
+			return use
+		}
+		if p.outer != nil || p.center != nil {
+			canvas.AppendChildren(place(p.name))
+		}
+		if p.outer == nil || p.center == nil {
+			inked = append(inked, place(p.name+"ink"))
 		}
 	}
 
-	// Superimpose alignment patterns (for versions >= 2)
-	if dim >= 21+4 {
-		for _, ap := range req.AlignmentPatterns {
+	defs := append([]svg.Element{circle, square, rounded, squircle}, patternDefs...)
+	if r.Compact {
+		// Only the definitions in use
+		used := map[Shape]bool{s.Shape: true}
+		if s.Logo != nil {
+			used[s.Logo.Plate.shape()] = true
+		}
+		defs = []svg.Element{square}
+		if used[ShapeCircle] {
+			defs = append(defs, circle)
+		}
+		if used[ShapeRounded] {
+			defs = append(defs, rounded)
+		}
+		if used[ShapeSquircle] {
+			defs = append(defs, squircle)
+		}
+		if overlays {
+			defs = append(defs, patternDefs...)
+		}
+	}
+	if gradient != nil {
+		defs = append(defs, svgGradient(gradient, gradient.geometry(0, 0, float64(dim)), id("gradient"), num))
+	}
+	canvas.AppendChildren(svg.Defs(defs...))
+
+	// A quiet zone color of its own paints a frame around the symbol, so a
+	// translucent background does not cover it
+	margin := s.QuietZoneColor
+	if margin == nil {
+		margin = bg
+	}
+	if margin != bg {
+		if Visible(margin) {
+			frame := fmt.Sprintf("M%g %gh%gv%gh%gz M0 0v%dh%dv%dz", origin, origin, total, total, -total, dim, dim, -dim)
 			canvas.AppendChildren(
-				svg.Use().Href(svg.String("#alignmentpattern")).XY(float64(ap[0]-2), float64(ap[1]-2), svg.Number),
+				svg.Path().D(svg.String(frame)).Style(svg.String(Paint("fill", margin) + ";fill-rule:evenodd")),
 			)
 		}
+		if Visible(bg) {
+			canvas.AppendChildren(
+				svg.Rect().XYWidthHeight(0, 0, float64(dim), float64(dim), svg.Number).Style(svg.String(Paint("fill", bg))),
+			)
+		}
+	} else if Visible(margin) {
+		canvas.AppendChildren(
+			svg.Rect().XYWidthHeight(origin, origin, total, total, svg.Number).Style(svg.String(Paint("fill", margin))),
+		)
 	}
 
-	// Superimpose finder patterns
-	canvas.AppendChildren(
-		svg.Use().Href(svg.String("#finderpattern")).XY(0, 0, svg.Number),
-		svg.Use().Href(svg.String("#finderpattern")).XY(float64(dim-7), 0, svg.Number),
-		svg.Use().Href(svg.String("#finderpattern")).XY(0, float64(dim-7), svg.Number),
-	)
-
-	// Ensure logo size is always odd
-	logoSize := int(math.Floor(float64(dim) * logoRelativeSize))
-	logoSize += (logoSize + 1) % 2
+	// Draw Modules
+	switch {
+	case s.Shape.connected():
+		// The outline of a module depends on its neighbors, one path per
+		// color holds them all
+		for _, fill := range s.moduleFills(!overlays) {
+			paint := Paint("fill", fill)
+			if fill == nil {
+				paint = "fill:url(#" + id("gradient") + ")"
+			}
+			canvas.AppendChildren(
+				svg.Path().D(svg.String(connectedPath(s, fill, overlays, num))).Style(svg.String(paint)),
+			)
+		}
+	case r.Compact && s.Shape == ShapeSquare:
+		// One path per color, drawn in the user space of the symbol where
+		// the gradient can fill it directly
+		for _, fill := range s.moduleFills(!overlays) {
+			paint := Paint("fill", fill)
+			if fill == nil {
+				paint = "fill:url(#" + id("gradient") + ")"
+			}
+			canvas.AppendChildren(
+				svg.Path().D(svg.String(contourPath(s, fill, overlays))).Style(svg.String(paint)),
+			)
+		}
+	case r.Compact:
+		// One class per color
+		fills := s.moduleFills(false)
+		class := func(i int) string {
+			if i == 0 {
+				return id("m")
+			}
+			return id(fmt.Sprintf("m%d", i))
+		}
+		var rules strings.Builder
+		for i, fill := range fills {
+			fg, gap := colors(fill)
+			fmt.Fprintf(&rules, ".%s{%s}", class(i), style(s.Shape, fg, gap, color.Black, 1.0))
+		}
+		canvas.AppendChildren(svg.Style(svg.CharData(rules.String())))
+		for y, row := range s.Modules {
+			for x, m := range row {
+				if m.Dark && !s.IsOverlay(x, y) && !s.Cleared(x, y) {
+					fill := s.moduleFill(x, y)
+					ink(fill, svg.Use().XY(float64(x), float64(y), svg.Number).Href(shapeRef).Class(svg.String(class(slices.Index(fills, fill)))))
+				}
+			}
+		}
+	default:
+		for y, row := range s.Modules {
+			for x, m := range row {
+				if m.Dark && !s.IsOverlay(x, y) && !s.Cleared(x, y) {
+					fill := s.moduleFill(x, y)
+					fg, gap := colors(fill)
+					ink(fill, svg.Use().XY(float64(x), float64(y), svg.Number).Href(shapeRef).Style(
+						svg.String(style(s.Shape, fg, gap, color.Black, 1.0)),
+					))
+				}
+			}
+		}
+	}
 
-	logoPos := dim/2. - logoSize/2.
+	// Superimpose alignment patterns (for versions >= 2)
+	if overlays && dim >= 21+4 {
+		for _, ap := range s.AlignmentPatterns {
+			overlay(alignmentPattern, ap[0]-2, ap[1]-2)
+		}
+	}
 
-	logoCenter := float64(logoPos) + float64(logoSize)/2.
+	// Superimpose finder patterns
+	for _, f := range s.Finders {
+		if overlays {
+			overlay(finderPattern, f[0], f[1])
+		}
+	}
 
-	// Draw logo ensuring a minimum size of 5 modules
-	if req.Logo != "" && logoSize >= 5 {
+	if len(inked) > 0 {
+		canvas.AppendChildren(
+			svg.Mask(inked...).ID(svg.String(id("ink"))),
+			svg.Rect().XYWidthHeight(0, 0, float64(dim), float64(dim), svg.Number).
+				Style(svg.String("fill:url(#"+id("gradient")+")")).
+				Mask(svg.String("url(#"+id("ink")+")")),
+		)
+	}
 
-		// Create safe zone around logo
-		var padding float64
-		if req.Shape != ShapeSquare {
-			padding = 2.0
-		} else {
-			padding = 1.0
-		}
-		startCell := logoPos - 1
-		endCell := logoPos + logoSize + 1
-
-		radius := float64(logoSize)/2. + padding
-		for y := startCell; y < endCell; y++ {
-			for x := startCell; x < endCell; x++ {
-				dx := float64(x) + .5 - logoCenter
-				dy := float64(y) + .5 - logoCenter
-				distance := dx*dx + dy*dy
-				if distance < radius*radius {
-					canvas.AppendChildren(
-						svg.Use().XY(float64(x), float64(y), svg.Number).Href("#square").Style("fill:white"),
-					)
-				}
-			}
+	if logo := s.Logo; logo != nil {
+		// The plate and its border are half a module larger than the logo
+		plate := logo.Plate.shape()
+		plateRef := svg.String("#" + id(string(plate)))
+		plateScale := float64(logo.Size) + 1.0
+		plateX, plateY := float64(logo.X)-0.5, float64(logo.Y)-0.5
+		if logo.PlateColor != nil && logo.Plate != PlateNone && logo.Plate != PlateShape {
+			plateFill := svg.Use().Href(plateRef).Style(svg.String(Paint("fill", logo.PlateColor)))
+			plateFill.Attrs["transform"] = svg.String(transformXY(plate, plateScale, plateX, plateY))
+			canvas.AppendChildren(plateFill)
+		}
+		if logo.BorderWidth > 0 {
+			logoBorder := svg.Use().Href(plateRef).Style(svg.String(fmt.Sprintf("fill:none;%s;stroke-width:%s", Paint("stroke", s.Color), num(logo.BorderWidth/plateScale))))
+			logoBorder.Attrs["transform"] = svg.String(transformXY(plate, plateScale, plateX, plateY))
+			canvas.AppendChildren(logoBorder)
 		}
 
 		// Place logo with clipping path
-		logoClipPath := svg.Use().Href(svg.String("#" + string(req.Shape)))
-		logoClipPath.Attrs["transform"] = svg.String(GetTransform(req.Shape, float64(logoSize), float64(logoPos), 0.))
-
-		logoBorderScale := float64(logoSize) + 1.0
-		logoBorderPos := float64(logoPos) - 0.5
-		logoBorder := svg.Use().Href(svg.String("#" + string(req.Shape))).Style(svg.String(fmt.Sprintf("fill:none;stroke:%s;stroke-width:%f", ColorToFill(req.Color), logoBorderWidth/logoBorderScale)))
-		logoBorder.Attrs["transform"] = svg.String(GetTransform(req.Shape, logoBorderScale, logoBorderPos, 0.))
-		if req.Shape == ShapeSquare {
-			logoBorder.Visibility(svg.String("hidden"))
+		logoClipPath := svg.Use().Href(plateRef)
+		logoClipPath.Attrs["transform"] = svg.String(transformXY(plate, float64(logo.Size), float64(logo.X), float64(logo.Y)))
+		image, err := logoElement(logo, "url(#"+id("logoClip")+")", id("logo-"), r.LinkLogo)
+		if err != nil {
+			return err
 		}
-
 		canvas.AppendChildren(
-			svg.ClipPath(logoClipPath).ID("logoClip"),
-			logoBorder,
-			svg.Image().Href(svg.String(req.Logo)).XYWidthHeight(
-				float64(logoPos), float64(logoPos), float64(logoSize), float64(logoSize), svg.Number,
-			).ClipPath("url(#logoClip)"),
+			svg.ClipPath(logoClipPath).ID(svg.String(id("logoClip"))),
+			image,
 		)
 	}
 
-	// Write to file
-	if _, err := canvas.WriteToIndent(file, "", "  "); err != nil {
-		panic(err)
+	if r.Sprite {
+		symbol := svg.Symbol(canvas.Children...).ID(svg.String(id("code"))).ViewBox(origin, origin, total, total)
+		canvas = svg.New(symbol).WidthHeight(0, 0, svg.Number)
+		canvas.Attrs["aria-hidden"] = svg.String("true")
+		canvas.Attrs["style"] = svg.String("position:absolute")
 	}
+
+	indent := "  "
+	if r.Compact {
+		indent = ""
+	}
+	_, err := canvas.WriteToIndent(w, "", indent)
+	return err
 }
 
 func GetTransform(shape Shape, scale float64, pos float64, padding float64) string {
+	return shapeTransform(shape, scale, pos, padding, fixedNumber)
+}
+
+func shapeTransform(shape Shape, scale, pos, padding float64, num func(float64) string) string {
+	return shapeTransformXY(shape, scale, pos, pos, padding, num)
+}
+
+// shapeTransformXY places a shape definition over the square of side scale
+// at (x, y)
+func shapeTransformXY(shape Shape, scale, x, y, padding float64, num func(float64) string) string {
 	switch shape {
 	case ShapeSquare:
 		padding = 0
 	}
-	return fmt.Sprintf("scale(%f) translate(%f, %f)", scale-padding, (pos+padding/2.)/(scale-padding), (pos+padding/2.)/(scale-padding))
+	side := scale - padding
+	return fmt.Sprintf("scale(%s) translate(%s, %s)", num(side), num((x+padding/2.)/side), num((y+padding/2.)/side))
+}
+
+func GetStyle(shape Shape, target, background, source color.Color, scale float64) string {
+	return shapeStyle(shape, target, background, source, scale, fixedNumber)
 }
 
-func GetStyle(shape Shape, target, source color.Color, scale float64) string {
-	var fill color.Color
-	fill = color.White
+func shapeStyle(shape Shape, target, background, source color.Color, scale float64, num func(float64) string) string {
+	fill := background
 	if source == color.Black {
 		fill = target
 	}
@@ -245,23 +508,107 @@ func GetStyle(shape Shape, target, source color.Color, scale float64) string {
 
 	switch shape {
 	case ShapeSquare:
-		return fmt.Sprintf("fill:%s;stroke:none", ColorToFill(fill))
+		return fmt.Sprintf("%s;stroke:none", Paint("fill", fill))
 	default:
-		return fmt.Sprintf("fill:%s;stroke:white;stroke-width:%f", ColorToFill(fill), width)
+		return fmt.Sprintf("%s;%s;stroke-width:%s", Paint("fill", fill), Paint("stroke", background), num(width))
 	}
 }
 
-func NoStrokeStyle(target, source color.Color) string {
+func NoStrokeStyle(target, background, source color.Color) string {
 	if source == color.Black {
-		return fmt.Sprintf("fill:%s;stroke:none", ColorToFill(target))
+		return fmt.Sprintf("%s;stroke:none", Paint("fill", target))
+	}
+	return fmt.Sprintf("%s;stroke:none", Paint("fill", background))
+}
+
+// fixedNumber formats v with 6 decimals, as the indented output always did
+func fixedNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
+
+// roundedNumber formats v with at most precision decimals
+func roundedNumber(v float64, precision int) string {
+	scale := math.Pow10(precision)
+	return strconv.FormatFloat(math.Round(v*scale)/scale, 'f', -1, 64)
+}
+
+// contourPath returns the path data outlining the dark modules painted with
+// fill that are not hidden by the logo, leaving out the finder and
+// alignment patterns when overlays draw them
+func contourPath(s *Scene, fill color.Color, overlays bool) string {
+	var b strings.Builder
+	contours := traceContours(s.Size(), func(x, y int) bool {
+		return s.Dark(x, y) && !s.Cleared(x, y) && (!overlays || !s.IsOverlay(x, y)) && s.moduleFill(x, y) == fill
+	})
+	for _, contour := range contours {
+		fmt.Fprintf(&b, "M%d %d", contour[0].X, contour[0].Y)
+		for i, p := range contour[1:] {
+			// contours only have horizontal and vertical edges
+			if p.X != contour[i].X {
+				fmt.Fprintf(&b, "H%d", p.X)
+			} else {
+				fmt.Fprintf(&b, "V%d", p.Y)
+			}
+		}
+		b.WriteByte('Z')
 	}
-	return "fill:white;stroke:none"
+	return b.String()
 }
 
-func ColorToFill(c color.Color) string {
-	if c == nil {
-		return "none"
+// autoIDPrefix returns a prefix made of a hash of everything that ends up
+// in an element with an id: the encoded data and the modules, which differ
+// between levels, the style, the quiet zone and the logo with its
+// placement. Two codes drawn differently in one page do not collide.
+func autoIDPrefix(s *Scene) string {
+	h := fnv.New32a()
+	paint := func(c color.Color) { fmt.Fprintf(h, "%s;", Paint("", c)) }
+	fmt.Fprintf(h, "%s;%s;%s;%d;", s.Shape, s.EyeFrame, s.EyeBall, s.QuietZone)
+	for _, c := range []color.Color{
+		s.Color, s.Background, s.QuietZoneColor,
+		s.Colors.Data, s.Colors.Timing, s.Colors.FinderOuter, s.Colors.FinderCenter, s.Colors.Alignment,
+	} {
+		paint(c)
+	}
+	if g := s.Gradient; g != nil {
+		fmt.Fprintf(h, "%s;%g;%g;%g;%g;%t;", g.Kind, g.Angle, g.CenterX, g.CenterY, g.Radius, g.Finders)
+		for _, stop := range g.Stops {
+			fmt.Fprintf(h, "%g:", stop.Offset)
+			paint(stop.Color)
+		}
+	}
+	if logo := s.Logo; logo != nil {
+		fmt.Fprintf(h, "%s;%d;%d;%d;%s;%g;", logo.Href, logo.X, logo.Y, logo.Size, logo.Plate, logo.BorderWidth)
+		paint(logo.PlateColor)
+		if img := logo.Image; img != nil {
+			bounds := img.Bounds()
+			fmt.Fprintf(h, "%v;", bounds)
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					r, g, b, a := img.At(x, y).RGBA()
+					binary.Write(h, binary.LittleEndian, [4]uint16{uint16(r), uint16(g), uint16(b), uint16(a)})
+				}
+			}
+		}
+		// The cleared modules follow the plate and its margin
+		for _, row := range logo.Cleared {
+			for _, cleared := range row {
+				if cleared {
+					h.Write([]byte{1})
+				} else {
+					h.Write([]byte{0})
+				}
+			}
+		}
+	}
+	fmt.Fprintf(h, "%d:%s;", len(s.Data), s.Data)
+	for _, row := range s.Modules {
+		for _, m := range row {
+			if m.Dark {
+				h.Write([]byte{1})
+			} else {
+				h.Write([]byte{0})
+			}
+		}
 	}
-	r, g, b, _ := c.RGBA()
-	return fmt.Sprintf("rgb(%d %d %d)", r>>8, g>>8, b>>8)
+	return fmt.Sprintf("qr%08x-", h.Sum32())
 }
@@ -0,0 +1,201 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// svgNode is an element of a parsed SVG document
+type svgNode struct {
+	name     string
+	attrs    map[string]string
+	children []*svgNode
+}
+
+// parseSVG renders s with r and parses the document
+func parseSVG(t *testing.T, r SVGRenderer, s *Scene) (*svgNode, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	root := &svgNode{}
+	stack := []*svgNode{root}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			node := &svgNode{name: token.Name.Local, attrs: map[string]string{}}
+			for _, attr := range token.Attr {
+				node.attrs[attr.Name.Local] = attr.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(root.children) != 1 || root.children[0].name != "svg" {
+		t.Fatalf("no svg root in\n%s", buf.String())
+	}
+	return root.children[0], buf.String()
+}
+
+// all returns the elements named name below n
+func (n *svgNode) all(name string) []*svgNode {
+	var found []*svgNode
+	for _, child := range n.children {
+		if child.name == name {
+			found = append(found, child)
+		}
+		found = append(found, child.all(name)...)
+	}
+	return found
+}
+
+func TestAutoIDPrefix(t *testing.T) {
+	base := func() *Scene {
+		s := blankScene(21)
+		s.Data = "hello"
+		s.Logo = &Logo{Href: "logo.png", X: 8, Y: 8, Size: 5, Plate: PlateCircle}
+		return s
+	}
+	// Every change drawn into an element with an id changes the prefix
+	changes := map[string]func(s *Scene){
+		"logo file":     func(s *Scene) { s.Logo.Href = "other.png" },
+		"logo position": func(s *Scene) { s.Logo.X = 6 },
+		"logo size":     func(s *Scene) { s.Logo.Size = 7 },
+		"logo plate":    func(s *Scene) { s.Logo.Plate = PlateRounded },
+		"plate color":   func(s *Scene) { s.Logo.PlateColor = color.White },
+		"quiet zone":    func(s *Scene) { s.QuietZone = 2 },
+		"quiet color":   func(s *Scene) { s.QuietZoneColor = color.Black },
+		"modules":       func(s *Scene) { s.Modules[10][10].Dark = true },
+		"eyes":          func(s *Scene) { s.EyeFrame = EyeCircle },
+	}
+	prefix := autoIDPrefix(base())
+	if again := autoIDPrefix(base()); again != prefix {
+		t.Errorf("same scene hashed to %s and %s", prefix, again)
+	}
+	for name, change := range changes {
+		s := base()
+		change(s)
+		if autoIDPrefix(s) == prefix {
+			t.Errorf("%s does not change the prefix %s", name, prefix)
+		}
+	}
+}
+
+func TestSVGSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		renderer      SVGRenderer
+		quietZone     int
+		viewBox       string
+		width, height string
+	}{
+		{"default scale", SVGRenderer{}, 4, "-4 -4 29 29", "464", "464"},
+		{"scale", SVGRenderer{Scale: 2}, 4, "-4 -4 29 29", "58", "58"},
+		{"no quiet zone", SVGRenderer{Scale: 2}, 0, "0 0 21 21", "42", "42"},
+		{"width", SVGRenderer{Width: 300}, 4, "-4 -4 29 29", "300", "300"},
+		{"height", SVGRenderer{Height: 200}, 2, "-2 -2 25 25", "200", "200"},
+		{"width and height", SVGRenderer{Width: 300, Height: 200}, 4, "-4 -4 29 29", "300", "200"},
+		{"compact", SVGRenderer{Compact: true}, 1, "-1 -1 23 23", "368", "368"},
+	}
+	for _, tt := range tests {
+		s := blankScene(21)
+		s.QuietZone = tt.quietZone
+		root, _ := parseSVG(t, tt.renderer, s)
+		if got := root.attrs["viewBox"]; got != tt.viewBox {
+			t.Errorf("%s: viewBox %q, want %q", tt.name, got, tt.viewBox)
+		}
+		if root.attrs["width"] != tt.width || root.attrs["height"] != tt.height {
+			t.Errorf("%s: %sx%s, want %sx%s", tt.name, root.attrs["width"], root.attrs["height"], tt.width, tt.height)
+		}
+	}
+}
+
+func TestSVGCompact(t *testing.T) {
+	s := blankScene(21)
+	s.Modules[5][3].Dark = true
+	s.Modules[5][4].Dark = true
+	s.Modules[9][9].Dark = true
+
+	// The square shape is traced into a single path, without overlays
+	root, out := parseSVG(t, SVGRenderer{Compact: true}, s)
+	if strings.Contains(out, "\n") {
+		t.Errorf("compact document is indented:\n%s", out)
+	}
+	paths := root.all("path")
+	if len(paths) != 1 || paths[0].attrs["d"] != "M3 5H5V6H3ZM9 9H10V10H9Z" {
+		t.Errorf("paths %v, want the two runs of modules", paths)
+	}
+	if uses := root.all("use"); len(uses) != 0 {
+		t.Errorf("%d use elements", len(uses))
+	}
+
+	// Other shapes share a class
+	s.Shape = ShapeCircle
+	root, _ = parseSVG(t, SVGRenderer{Compact: true, IDPrefix: "c-"}, s)
+	styles := root.all("style")
+	if len(styles) != 1 {
+		t.Fatalf("%d style elements", len(styles))
+	}
+	modules := 0
+	for _, use := range root.all("use") {
+		if use.attrs["href"] == "#c-circle" && use.attrs["class"] == "c-m" {
+			modules++
+			if use.attrs["style"] != "" {
+				t.Errorf("module with an inline style %q", use.attrs["style"])
+			}
+		}
+	}
+	if modules != 3 {
+		t.Errorf("%d modules with the c-m class, want 3", modules)
+	}
+}
+
+func TestSVGSprite(t *testing.T) {
+	s := blankScene(21)
+	s.Modules[5][3].Dark = true
+	s.Shape = ShapeRounded
+	root, _ := parseSVG(t, SVGRenderer{Sprite: true, IDPrefix: "p-"}, s)
+	if root.attrs["width"] != "0" || root.attrs["height"] != "0" || root.attrs["aria-hidden"] != "true" {
+		t.Errorf("sprite document attributes %v", root.attrs)
+	}
+	if len(root.children) != 1 || root.children[0].name != "symbol" {
+		t.Fatalf("sprite document holds %v", root.children)
+	}
+	symbol := root.children[0]
+	if symbol.attrs["id"] != "p-code" || symbol.attrs["viewBox"] != "-4 -4 29 29" {
+		t.Errorf("symbol attributes %v", symbol.attrs)
+	}
+
+	// Every id is prefixed and every reference points inside the sprite
+	ids := map[string]bool{}
+	var walk func(n *svgNode)
+	walk = func(n *svgNode) {
+		if id, ok := n.attrs["id"]; ok {
+			if !strings.HasPrefix(id, "p-") {
+				t.Errorf("id %q without the prefix", id)
+			}
+			ids[id] = true
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(symbol)
+	for _, use := range symbol.all("use") {
+		if href := use.attrs["href"]; !ids[strings.TrimPrefix(href, "#")] {
+			t.Errorf("use of %q, which is not defined", href)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+// Graphics is a terminal image protocol.
+type Graphics string
+
+const (
+	GraphicsNone  Graphics = "none"
+	GraphicsSixel Graphics = "sixel"
+	GraphicsKitty Graphics = "kitty"
+)
+
+const (
+	// ANSI reverse video on/off
+	ansiInvert = "\x1b[7m"
+	ansiReset  = "\x1b[0m"
+	// kitty graphics payloads must be sent in chunks of at most 4096 bytes
+	kittyChunkSize = 4096
+	// pixels per module used by the graphics protocols
+	defaultTerminalScale = 4
+)
+
+// TerminalRenderer draws symbols on a terminal.
+type TerminalRenderer struct {
+	// Invert swaps the foreground and background of the text rendering so
+	// the symbol keeps its polarity on terminals with light text.
+	Invert   bool
+	Graphics Graphics
+	// Scale is the number of pixels per module for sixel and kitty output.
+	Scale int
+}
+
+// DetectGraphics guesses the image protocol supported by the terminal
+// from the environment. It returns GraphicsNone when unsure.
+func DetectGraphics() Graphics {
+	term := os.Getenv("TERM")
+	program := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "", term == "xterm-kitty", term == "xterm-ghostty",
+		program == "WezTerm", program == "ghostty":
+		return GraphicsKitty
+	case strings.Contains(term, "sixel"), term == "foot", strings.HasPrefix(term, "mlterm"),
+		strings.HasPrefix(term, "yaft"), program == "iTerm.app":
+		return GraphicsSixel
+	}
+	return GraphicsNone
+}
+
+// Render draws the symbol, including its quiet zone, to w. Without a
+// graphics protocol every line of text holds two module rows using Unicode
+// half blocks.
+func (r TerminalRenderer) Render(w io.Writer, s *Scene) error {
+	if r.Scale < 1 {
+		r.Scale = defaultTerminalScale
+	}
+	bw := bufio.NewWriter(w)
+	var err error
+	switch r.Graphics {
+	case GraphicsSixel:
+		err = writeSixel(bw, rasterize(s, r.Scale))
+	case GraphicsKitty:
+		err = writeKitty(bw, rasterize(s, r.Scale))
+	default:
+		err = writeHalfBlocks(bw, s, r.Invert)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeHalfBlocks(w *bufio.Writer, s *Scene, invert bool) error {
+	dim := s.Size() + 2*s.QuietZone
+	dark := func(x, y int) bool {
+		return s.Dark(x-s.QuietZone, y-s.QuietZone)
+	}
+
+	for y := 0; y < dim; y += 2 {
+		if invert {
+			w.WriteString(ansiInvert)
+		}
+		for x := range dim {
+			top, bottom := dark(x, y), dark(x, y+1)
+			switch {
+			case top && bottom:
+				w.WriteRune('█')
+			case top:
+				w.WriteRune('▀')
+			case bottom:
+				w.WriteRune('▄')
+			default:
+				w.WriteRune(' ')
+			}
+		}
+		if invert {
+			w.WriteString(ansiReset)
+		}
+		if _, err := w.WriteRune('\n'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSixel encodes a two color image in the DEC sixel format. Each sixel
+// character holds a column of 6 pixels for one color register.
+func writeSixel(w *bufio.Writer, img *image.Paletted) error {
+	bounds := img.Bounds()
+	fmt.Fprintf(w, "\x1bPq\"1;1;%d;%d", bounds.Dx(), bounds.Dy())
+	for i, c := range img.Palette {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(w, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+
+	for band := bounds.Min.Y; band < bounds.Max.Y; band += 6 {
+		for idx := range img.Palette {
+			fmt.Fprintf(w, "#%d", idx)
+			var last byte
+			run := 0
+			flush := func() {
+				switch {
+				case run > 3:
+					fmt.Fprintf(w, "!%d%c", run, last)
+				case run > 0:
+					w.WriteString(strings.Repeat(string(last), run))
+				}
+			}
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				var bits byte
+				for dy := range 6 {
+					y := band + dy
+					if y < bounds.Max.Y && int(img.ColorIndexAt(x, y)) == idx {
+						bits |= 1 << dy
+					}
+				}
+				ch := 63 + bits
+				if ch == last {
+					run++
+					continue
+				}
+				flush()
+				last, run = ch, 1
+			}
+			flush()
+			// return to the start of the band for the next color
+			w.WriteByte('$')
+		}
+		w.WriteByte('-')
+	}
+	_, err := w.WriteString("\x1b\\\n")
+	return err
+}
+
+// writeKitty transmits img as PNG using the kitty graphics protocol.
+func writeKitty(w *bufio.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	payload := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	for i := 0; i < len(payload); i += kittyChunkSize {
+		end := min(i+kittyChunkSize, len(payload))
+		more := 0
+		if end < len(payload) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(w, "\x1b_Gf=100,a=T,m=%d;%s\x1b\\", more, payload[i:end])
+		} else {
+			fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, payload[i:end])
+		}
+	}
+	_, err := w.WriteString("\n")
+	return err
+}
@@ -0,0 +1,132 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTerminalRenderer(t *testing.T) {
+	tests := []struct {
+		name     string
+		renderer TerminalRenderer
+		want     string
+	}{
+		{"half blocks", TerminalRenderer{}, " ▄  \n ▀▀ \n"},
+		{"inverted", TerminalRenderer{Invert: true}, "\x1b[7m ▄  \x1b[0m\n\x1b[7m ▀▀ \x1b[0m\n"},
+		// Each sixel is a column of pixels, 63 plus one bit per row
+		{"sixel", TerminalRenderer{Graphics: GraphicsSixel, Scale: 1},
+			"\x1bPq\"1;1;4;4#0;2;100;100;100#1;2;0;0;0#0NHJN$#1?EC?$-\x1b\\\n"},
+	}
+	for _, tt := range tests {
+		s := sceneFromRows("#.", "##")
+		s.QuietZone = 1
+		var buf bytes.Buffer
+		if err := tt.renderer.Render(&buf, s); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s:\n%q, want\n%q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSixelRuns(t *testing.T) {
+	// 29 blank columns are written as one repeated sixel per color
+	var buf bytes.Buffer
+	if err := (TerminalRenderer{Graphics: GraphicsSixel, Scale: 1}).Render(&buf, blankScene(21)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "#0!29~$#1!29?$-"; !strings.Contains(buf.String(), want) {
+		t.Errorf("no %q in %q", want, buf.String())
+	}
+}
+
+func TestKittyChunks(t *testing.T) {
+	// Noise compresses badly, so the PNG takes several chunks
+	img := image.NewPaletted(image.Rect(0, 0, 200, 200), color.Palette{color.White, color.Black})
+	seed := uint32(1)
+	for i := range img.Pix {
+		seed = seed*1664525 + 1013904223
+		img.Pix[i] = uint8(seed >> 31)
+	}
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	if err := writeKitty(w, img); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+
+	chunks := regexp.MustCompile("\x1b_G([^;]*);([^\x1b]*)\x1b\\\\").FindAllStringSubmatch(buf.String(), -1)
+	if len(chunks) < 2 {
+		t.Fatalf("%d chunks", len(chunks))
+	}
+	var payload strings.Builder
+	for i, chunk := range chunks {
+		want := "m=1"
+		if i == len(chunks)-1 {
+			want = "m=0"
+		}
+		if i == 0 {
+			want = "f=100,a=T," + want
+		}
+		if chunk[1] != want {
+			t.Errorf("chunk %d control data %q, want %q", i, chunk[1], want)
+		}
+		if len(chunk[2]) > kittyChunkSize {
+			t.Errorf("chunk %d of %d bytes", i, len(chunk[2]))
+		}
+		payload.WriteString(chunk[2])
+	}
+	data, err := base64.StdEncoding.DecodeString(payload.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := range 200 {
+		for x := range 200 {
+			if !sameColor(decoded.At(x, y), img.At(x, y)) {
+				t.Fatalf("pixel (%d,%d) differs", x, y)
+			}
+		}
+	}
+}
+
+func TestDetectGraphics(t *testing.T) {
+	tests := []struct {
+		term, program, kitty string
+		want                 Graphics
+	}{
+		{"xterm-kitty", "", "", GraphicsKitty},
+		{"xterm-256color", "", "1", GraphicsKitty},
+		{"xterm-256color", "WezTerm", "", GraphicsKitty},
+		{"xterm-256color", "iTerm.app", "", GraphicsSixel},
+		{"foot", "", "", GraphicsSixel},
+		{"mlterm-256color", "", "", GraphicsSixel},
+		{"xterm-256color", "Apple_Terminal", "", GraphicsNone},
+		{"", "", "", GraphicsNone},
+	}
+	for _, tt := range tests {
+		t.Setenv("TERM", tt.term)
+		t.Setenv("TERM_PROGRAM", tt.program)
+		t.Setenv("KITTY_WINDOW_ID", tt.kitty)
+		if got := DetectGraphics(); got != tt.want {
+			t.Errorf("TERM=%q TERM_PROGRAM=%q KITTY_WINDOW_ID=%q: %s, want %s", tt.term, tt.program, tt.kitty, got, tt.want)
+		}
+	}
+}
+
+func sameColor(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
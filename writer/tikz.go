@@ -0,0 +1,145 @@
+package writer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// TikZUnit is a TeX length unit for the module size.
+type TikZUnit string
+
+const (
+	TikZPoint      TikZUnit = "pt"
+	TikZMillimetre TikZUnit = "mm"
+)
+
+// TikZRenderer writes a tikzpicture to be included with \input{}. The dark
+// regions are filled as merged paths and the colors are defined with xcolor,
+// in the cmyk model for print colors. The logo file is placed with
+// \includegraphics, so it must be a PNG, JPEG or PDF file found from the
+// document, and images prepared in memory cannot be drawn.
+type TikZRenderer struct {
+	// ModuleSize is the side of a module in Unit.
+	ModuleSize float64
+	Unit       TikZUnit
+}
+
+// Render writes the tikzpicture to w.
+func (r TikZRenderer) Render(w io.Writer, s *Scene) error {
+	if r.Unit == "" {
+		r.Unit = TikZMillimetre
+	}
+	if r.ModuleSize <= 0 {
+		r.ModuleSize = defaultModuleMM
+	}
+	if err := checkTikZLogo(s.Logo); err != nil {
+		return err
+	}
+
+	total := s.Size() + 2*s.QuietZone
+	bw := bufio.NewWriter(w)
+	bw.WriteString("% QR Code generated by go-mosaic, requires \\usepackage{tikz}\n")
+	fmt.Fprintf(bw, "\\definecolor{mosaicfg}%s\n", tikzColor(s.Color))
+	fmt.Fprintf(bw, "\\definecolor{mosaicbg}%s\n", tikzColor(s.Background))
+	margin := s.QuietZoneColor
+	if margin != nil && margin != s.Background {
+		fmt.Fprintf(bw, "\\definecolor{mosaicqz}%s\n", tikzColor(margin))
+	}
+	// The y axis points down to match the module grid
+	fmt.Fprintf(bw, "\\begin{tikzpicture}[x=%g%s,y=-%g%s]\n", r.ModuleSize, r.Unit, r.ModuleSize, r.Unit)
+	if margin != nil && margin != s.Background {
+		if Visible(margin) {
+			fmt.Fprintf(bw, "\\fill[mosaicqz,even odd rule] (0,0) rectangle (%d,%d) (%d,%d) rectangle (%d,%d);\n",
+				total, total, s.QuietZone, s.QuietZone, total-s.QuietZone, total-s.QuietZone)
+		}
+		if Visible(s.Background) {
+			fmt.Fprintf(bw, "\\fill[mosaicbg] (%d,%d) rectangle (%d,%d);\n", s.QuietZone, s.QuietZone, total-s.QuietZone, total-s.QuietZone)
+		}
+	} else if Visible(s.Background) {
+		fmt.Fprintf(bw, "\\fill[mosaicbg] (0,0) rectangle (%d,%d);\n", total, total)
+	}
+
+	contours := traceContours(s.Size(), func(x, y int) bool { return s.Dark(x, y) && !s.Cleared(x, y) })
+	if len(contours) > 0 {
+		bw.WriteString("\\fill[mosaicfg,even odd rule]")
+		for _, contour := range contours {
+			bw.WriteString("\n ")
+			for _, p := range contour {
+				fmt.Fprintf(bw, " (%d,%d) --", p.X+s.QuietZone, p.Y+s.QuietZone)
+			}
+			bw.WriteString(" cycle")
+		}
+		bw.WriteString(";\n")
+	}
+	if s.Logo != nil {
+		writeTikZLogo(bw, s, r.ModuleSize, r.Unit)
+	}
+	bw.WriteString("\\end{tikzpicture}\n")
+	return bw.Flush()
+}
+
+// checkTikZLogo fails for logos \includegraphics cannot read
+func checkTikZLogo(logo *Logo) error {
+	if logo == nil {
+		return nil
+	}
+	if logo.Image != nil {
+		return errors.New("tikz cannot include logo images prepared in memory, only logo files")
+	}
+	switch strings.ToLower(filepath.Ext(logo.Href)) {
+	case ".png", ".jpg", ".jpeg", ".pdf":
+		return nil
+	}
+	return fmt.Errorf("%s: tikz logos must be PNG, JPEG or PDF files", logo.Href)
+}
+
+// writeTikZLogo draws the plate, its border and the logo file clipped to
+// the plate, as paintScene does
+func writeTikZLogo(w *bufio.Writer, s *Scene, moduleSize float64, unit TikZUnit) {
+	logo := s.Logo
+	qz := float64(s.QuietZone)
+	x, y := float64(logo.X)+qz, float64(logo.Y)+qz
+	size := float64(logo.Size)
+	// The plate and its border are half a module larger than the logo
+	plate := logo.Plate.shape()
+	if logo.PlateColor != nil && logo.Plate != PlateNone && logo.Plate != PlateShape {
+		fmt.Fprintf(w, "\\definecolor{mosaicplate}%s\n", tikzColor(logo.PlateColor))
+		fmt.Fprintf(w, "\\fill[mosaicplate] %s;\n", tikzPolygon(scaledOutline(plate, size+1, x-0.5, y-0.5, 0)))
+	}
+	if width := logo.BorderWidth; width > 0 {
+		fmt.Fprintf(w, "\\fill[mosaicfg,even odd rule] %s %s;\n",
+			tikzPolygon(scaledOutline(plate, size+1+width, x-0.5-width/2, y-0.5-width/2, 0)),
+			tikzPolygon(scaledOutline(plate, size+1-width, x-0.5+width/2, y-0.5+width/2, 0)))
+	}
+	w.WriteString("\\begin{scope}\n")
+	fmt.Fprintf(w, "\\clip %s;\n", tikzPolygon(scaledOutline(plate, size, x, y, 0)))
+	side := pdfNumber(size*moduleSize) + string(unit)
+	fmt.Fprintf(w, "\\node[inner sep=0] at (%s,%s) {\\includegraphics[width=%s,height=%s,keepaspectratio]{%s}};\n",
+		pdfNumber(x+size/2), pdfNumber(y+size/2), side, side, filepath.ToSlash(logo.Href))
+	w.WriteString("\\end{scope}\n")
+}
+
+// tikzPolygon returns the closed path through points. TeX reads no
+// exponents, so the coordinates are written as PDF numbers.
+func tikzPolygon(points [][2]float64) string {
+	var b strings.Builder
+	for _, p := range points {
+		fmt.Fprintf(&b, "(%s,%s) -- ", pdfNumber(p[0]), pdfNumber(p[1]))
+	}
+	b.WriteString("cycle")
+	return b.String()
+}
+
+// tikzColor returns the model and values arguments of \definecolor for c
+func tikzColor(c color.Color) string {
+	if cmyk, ok := ToCMYK(c); ok {
+		return fmt.Sprintf("{cmyk}{%s,%s,%s,%s}", unitValue(cmyk.C), unitValue(cmyk.M), unitValue(cmyk.Y), unitValue(cmyk.K))
+	}
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("{RGB}{%d,%d,%d}", r>>8, g>>8, b>>8)
+}
@@ -0,0 +1,92 @@
+package writer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestTikZRenderer(t *testing.T) {
+	s := sceneFromRows("#.", "##")
+	s.QuietZone = 1
+	var buf bytes.Buffer
+	if err := (TikZRenderer{ModuleSize: 0.5}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	want := `% QR Code generated by go-mosaic, requires \usepackage{tikz}
+\definecolor{mosaicfg}{RGB}{0,0,0}
+\definecolor{mosaicbg}{RGB}{255,255,255}
+\begin{tikzpicture}[x=0.5mm,y=-0.5mm]
+\fill[mosaicbg] (0,0) rectangle (4,4);
+\fill[mosaicfg,even odd rule]
+  (1,1) -- (2,1) -- (2,2) -- (3,2) -- (3,3) -- (1,3) -- cycle;
+\end{tikzpicture}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTikZPrintColors(t *testing.T) {
+	s := sceneFromRows("#.", "##")
+	s.Color = pantone
+	s.QuietZoneColor = color.CMYK{C: 0xff}
+	var buf bytes.Buffer
+	if err := (TikZRenderer{Unit: TikZPoint}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`\definecolor{mosaicfg}{cmyk}{0,1,0.8,0}`,
+		`\definecolor{mosaicqz}{cmyk}{1,0,0,0}`,
+		`[x=1pt,y=-1pt]`,
+		`\fill[mosaicqz,even odd rule] (0,0) rectangle (10,10) (4,4) rectangle (6,6);`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("no %s in\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestTikZLogo(t *testing.T) {
+	s := blankScene(21)
+	s.QuietZone = 0
+	for y := range s.Modules {
+		for x := range s.Modules[y] {
+			s.Modules[y][x].Dark = true
+		}
+	}
+	cleared := make([][]bool, 21)
+	for y := range cleared {
+		cleared[y] = make([]bool, 21)
+		for x := 8; x < 13 && y >= 8 && y < 13; x++ {
+			cleared[y][x] = true
+		}
+	}
+	s.Logo = &Logo{Href: "logo.png", X: 8, Y: 8, Size: 5, Plate: PlateSquare, Cleared: cleared}
+	var buf bytes.Buffer
+	if err := (TikZRenderer{}).Render(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`(8,8) -- (8,13) -- (13,13) -- (13,8) -- cycle`,
+		`\clip (8,8) -- (13,8) -- (13,13) -- (8,13) -- cycle;`,
+		`\node[inner sep=0] at (10.5,10.5) {\includegraphics[width=5mm,height=5mm,keepaspectratio]{logo.png}};`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("no %s in\n%s", want, buf.String())
+		}
+	}
+
+	for _, logo := range []*Logo{
+		{Href: "logo.svg", Size: 5},
+		{Href: "logo.gif", Size: 5},
+		{Href: "logo.png", Image: image.NewGray(image.Rect(0, 0, 1, 1)), Size: 5},
+	} {
+		s.Logo = logo
+		if err := (TikZRenderer{}).Render(&bytes.Buffer{}, s); err == nil {
+			t.Errorf("%s: no error", logo.Href)
+		}
+	}
+}